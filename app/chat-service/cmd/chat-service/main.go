@@ -1,16 +1,24 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	pb "github.com/nsmsb/darda-chat/app/chat-service/internal/api/message/gen"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/auth"
 	"github.com/nsmsb/darda-chat/app/chat-service/internal/config"
 	"github.com/nsmsb/darda-chat/app/chat-service/internal/handler"
 	"github.com/nsmsb/darda-chat/app/chat-service/internal/middleware"
 	"github.com/nsmsb/darda-chat/app/chat-service/internal/service"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/storage"
 	"github.com/nsmsb/darda-chat/app/chat-service/pkg/logger"
 	"github.com/nsmsb/darda-chat/app/chat-service/pkg/rabbitmq"
 	"github.com/redis/go-redis/v9"
@@ -44,11 +52,6 @@ func main() {
 		Password: config.RedisPass,
 		DB:       config.RedisDB,
 	})
-	defer func() {
-		if err := redisClient.Close(); err != nil {
-			logger.Error("Error closing Redis connection", zap.Error(err))
-		}
-	}()
 
 	// Preparing AMQP Publisher
 
@@ -71,42 +74,105 @@ func main() {
 		logger.Fatal("Failed to create AMQP publisher", zap.Error(err))
 	}
 
-	// Preparing Message Service
-	messageService := service.NewRedisMessageService(redisClient, publisher)
-	// Closing Connection Gracefully on exit
-	defer func() {
-		if err := messageService.Close(); err != nil {
-			logger.Error("Error during closing Message Service", zap.Error(err))
+	// Preparing Presence Service, shared by all replicas through Redis
+	presenceService := service.NewRedisPresenceService(redisClient, config.InstanceID, config.PresenceTTL, config.PresenceHeartbeat)
+
+	// Preparing Message Service, delivering events through a channel per user or per replica, or a stream per user
+	var messageService service.MessageService
+	switch config.RoutingMode {
+	case "user":
+		messageService = service.NewRedisMessageService(redisClient, publisher, presenceService)
+	case "replica":
+		routeRegistry := service.NewRedisRouteRegistry(redisClient, config.InstanceID, config.RouteTTL, config.RouteHeartbeat)
+		messageService, err = service.NewRedisRoutedMessageService(context.Background(), redisClient, publisher, presenceService, routeRegistry, config.InstanceID)
+		if err != nil {
+			logger.Fatal("Failed to create routed message service", zap.Error(err))
 		}
-	}()
+	case "stream":
+		messageService = service.NewRedisStreamMessageService(redisClient, publisher, presenceService, config.InstanceID, config.StreamMaxLen, config.StreamTTL)
+	default:
+		logger.Fatal("Unsupported routing mode", zap.String("mode", config.RoutingMode))
+	}
+
+	// Preparing Deduplicator of retried messages
+	deduplicator := service.NewRedisDeduplicator(redisClient, config.DedupeTTL)
+
+	// Preparing Rate Limiter of events and connections, shared by all replicas through Redis
+	rateLimiter := service.NewRedisRateLimiter(redisClient)
 
 	// Preparing MessageReaderService
 	grpcConn, err := grpc.NewClient(config.MessageReaderServiceAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
 		log.Fatalf("Failed to connect to messageReaderService: %v", err)
 	}
-	defer grpcConn.Close()
 	messageReaderClient := pb.NewMessageServiceClient(grpcConn)
 
 	messageReaderService := service.NewMessageReaderService(messageReaderClient)
 
+	// Preparing Authenticator, verifying tokens against a JWKS endpoint or a local key file
+	var keySet auth.KeySet
+	switch {
+	case config.AuthJWKSURL != "":
+		keySet = auth.NewRemoteKeySet(config.AuthJWKSURL, config.AuthJWKSRefreshInterval)
+	case config.AuthKeyFile != "":
+		keySet, err = auth.LoadKeySetFile(config.AuthKeyFile)
+		if err != nil {
+			logger.Fatal("Failed to load auth keys", zap.Error(err))
+		}
+	default:
+		logger.Fatal("AUTH_JWKS_URL or AUTH_KEY_FILE is required")
+	}
+	authenticator := auth.NewJWTAuthenticator(keySet, config.AuthIssuer, config.AuthAudience)
+
+	// Preparing Attachment Service, storing files in the configured blob store
+	var blobStore storage.BlobStore
+	switch config.AttachmentStore {
+	case "local":
+		// A directory private to the replica would lose the files uploaded to the others
+		if config.AttachmentDir == "" {
+			logger.Fatal("ATTACHMENT_DIR is required for the local attachment store")
+		}
+		blobStore, err = storage.NewLocalBlobStore(config.AttachmentDir)
+		if err != nil {
+			logger.Fatal("Failed to prepare attachment store", zap.Error(err))
+		}
+	default:
+		logger.Fatal("Unsupported attachment store", zap.String("store", config.AttachmentStore))
+	}
+	attachmentService := service.NewBlobAttachmentService(blobStore, config.AttachmentMaxSize)
+
+	// Tracking long-lived connections, to drain them on shutdown
+	drainer := handler.NewDrainer()
+
 	// Preparing handlers
-	messageHandler := handler.NewMessageHandler(messageService, messageReaderService)
+	messageHandler := handler.NewMessageHandler(messageService, messageReaderService, presenceService, deduplicator, attachmentService, rateLimiter, drainer)
+	groupHandler := handler.NewGroupHandler(publisher, messageReaderService)
+	blockHandler := handler.NewBlockHandler(publisher, messageReaderService)
+	presenceHandler := handler.NewPresenceHandler(presenceService)
+	conversationHandler := handler.NewConversationHandler(publisher, messageReaderService)
 
 	// Router with no middlewares
 	r := gin.New()
 	r.Use(gin.Recovery())
 
+	// Client IPs are only read from X-Forwarded-For headers set by trusted proxies
+	if err := r.SetTrustedProxies(config.TrustedProxies); err != nil {
+		logger.Fatal("Invalid trusted proxies", zap.Error(err))
+	}
+
 	// Setting up CORS
 	if config.Env == "production" {
 		r.Use(cors.New(config.CORSConfig))
 	} else {
-		// allow all origins
-		r.Use(cors.Default())
+		// allow all origins, with the access tokens of the Authorization header
+		corsConfig := cors.DefaultConfig()
+		corsConfig.AllowAllOrigins = true
+		corsConfig.AddAllowHeaders("Authorization")
+		r.Use(cors.New(corsConfig))
 	}
 
 	// Adding Health Handler
-	healthHandler := handler.NewHealthHandler()
+	healthHandler := handler.NewHealthHandler(drainer)
 	r.GET("/healthz", healthHandler.Liveness)
 	r.GET("/readyz", healthHandler.Readiness)
 
@@ -116,14 +182,84 @@ func main() {
 	// Adding Middlewares
 	api.Use(middleware.RequestIDMiddleware())
 	api.Use(middleware.ZapLogger(logger))
+	api.Use(middleware.AuthMiddleware(authenticator))
 	// TODO: Add Error middleware
 
 	// Adding connections handler
 	api.GET("/ws", messageHandler.HandleConnections)
 	api.GET("/messages/:user", messageHandler.GetMessages)
+	api.GET("/threads/:message", messageHandler.GetThread)
+	api.GET("/receipts/:user", messageHandler.GetReceipts)
+
+	// Adding fallbacks of WebSockets: sending over HTTP, receiving through Server-Sent Events or long polling
+	api.POST("/messages", messageHandler.PostEvent)
+	api.GET("/events", messageHandler.StreamEvents)
+	api.GET("/poll", messageHandler.Poll)
+
+	// Adding attachments handlers
+	api.POST("/attachments", messageHandler.UploadAttachment)
+	api.GET("/attachments/:attachment", messageHandler.GetAttachment)
+
+	// Adding inbox handler
+	api.GET("/conversations", conversationHandler.ListConversations)
+	api.GET("/conversations/:user/notifications", conversationHandler.GetNotificationSettings)
+	api.PUT("/conversations/:user/notifications", conversationHandler.UpdateNotificationSettings)
+
+	// Adding group conversations handlers
+	api.POST("/groups", groupHandler.CreateGroup)
+	api.GET("/groups/:group", groupHandler.GetGroup)
+
+	// Adding blocking handlers
+	api.POST("/blocks", blockHandler.BlockUser)
+	api.DELETE("/blocks/:user", blockHandler.UnblockUser)
+	api.GET("/blocks", blockHandler.ListBlocks)
+
+	// Adding presence handler
+	api.GET("/presence/:user", presenceHandler.GetPresence)
 
 	// Running Server
 	addr := fmt.Sprintf("0.0.0.0:%s", config.Port)
-	logger.Info(fmt.Sprintf("WebSocket server started on %s\n", addr))
-	r.Run(addr)
+	server := &http.Server{
+		Addr:    addr,
+		Handler: r,
+	}
+	go func() {
+		logger.Info(fmt.Sprintf("WebSocket server started on %s\n", addr))
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Fatal("Failed to run server", zap.Error(err))
+		}
+	}()
+
+	// Graceful shutdown: Listen for interrupt or termination signal
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	logger.Info("Received shutdown signal, draining connections...")
+
+	// Refusing new connections and asking clients to reconnect elsewhere, spread over the drain window
+	ctx, cancel := context.WithTimeout(context.Background(), config.DrainWindow+config.ShutdownTimeout)
+	defer cancel()
+	if err := drainer.Drain(ctx, config.DrainWindow); err != nil {
+		logger.Error("Connections still open after draining", zap.Error(err))
+	}
+
+	// Waiting for in-flight requests, so sent messages are published before their dependencies are closed
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Error("Error during shutting down server", zap.Error(err))
+	}
+
+	// Releasing the connection slots of this replica's users
+	if err := rateLimiter.Close(); err != nil {
+		logger.Error("Error during closing Rate Limiter", zap.Error(err))
+	}
+
+	// Closing dependencies in order: Redis PubSub connections, AMQP channel, then gRPC client
+	if err := messageService.Close(); err != nil {
+		logger.Error("Error during closing Message Service", zap.Error(err))
+	}
+	if err := grpcConn.Close(); err != nil {
+		logger.Error("Error closing messageReaderService connection", zap.Error(err))
+	}
+
+	logger.Info("Gracefully shut down the chat service")
 }
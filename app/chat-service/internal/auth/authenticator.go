@@ -0,0 +1,18 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrMissingToken is returned when the request carries no bearer token.
+	ErrMissingToken = errors.New("missing token")
+	// ErrInvalidToken is returned when the token is malformed, expired or not signed by a trusted key.
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// Authenticator validates a bearer token and returns the ID of the user it was issued for.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (string, error)
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTAuthenticator validates JWTs signed with an asymmetric key of its KeySet, the subject being the user ID.
+type JWTAuthenticator struct {
+	keys     KeySet
+	issuer   string
+	audience string
+}
+
+// NewJWTAuthenticator creates a JWTAuthenticator, issuer and audience are only checked when not empty.
+func NewJWTAuthenticator(keys KeySet, issuer, audience string) *JWTAuthenticator {
+	return &JWTAuthenticator{
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+	}
+}
+
+func (a *JWTAuthenticator) Authenticate(ctx context.Context, tokenString string) (string, error) {
+	if tokenString == "" {
+		return "", ErrMissingToken
+	}
+
+	options := []jwt.ParserOption{
+		// Only asymmetric algorithms, so a public key can never be used as an HMAC secret
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithExpirationRequired(),
+	}
+	if a.issuer != "" {
+		options = append(options, jwt.WithIssuer(a.issuer))
+	}
+	if a.audience != "" {
+		options = append(options, jwt.WithAudience(a.audience))
+	}
+
+	var keyErr error
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := a.keys.Key(ctx, kid)
+		if err != nil && !errors.Is(err, ErrKeyNotFound) {
+			// Failing to load keys is not the client's fault
+			keyErr = err
+		}
+		return key, err
+	}, options...)
+	if keyErr != nil {
+		return "", keyErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	subject, err := token.Claims.GetSubject()
+	if err != nil || subject == "" {
+		return "", fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+	return subject, nil
+}
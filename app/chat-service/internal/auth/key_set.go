@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrKeyNotFound is returned when no key matches the key ID of a token.
+var ErrKeyNotFound = errors.New("key not found")
+
+// KeySet provides the public keys used to verify token signatures.
+type KeySet interface {
+	// Key returns the key with the given ID, or the only key of the set if kid is empty.
+	// Keys without ID match tokens with any key ID.
+	Key(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// staticKeySet is a KeySet whose keys never change.
+type staticKeySet map[string]crypto.PublicKey
+
+func (keys staticKeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	// A key without ID, such as a PEM key, is not told apart by key ID
+	if key, ok := keys[""]; ok {
+		return key, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+// LoadKeySetFile loads the keys from a local file, holding either a JWKS document or a PEM encoded public key.
+func LoadKeySetFile(path string) (KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	// PEM files hold a single key without ID, matching all tokens
+	if block, _ := pem.Decode(data); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse PEM public key: %w", err)
+		}
+		return staticKeySet{"": key}, nil
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+	return staticKeySet(keys), nil
+}
+
+// RemoteKeySet is a KeySet fetched from a JWKS endpoint and refreshed periodically,
+// or earlier when a token is signed with an unknown key (rotation), at most once per minRefreshInterval.
+// A single fetch runs at a time, outside of the lock, and the cached keys are served while the endpoint is down,
+// fetches backing off after failures.
+type RemoteKeySet struct {
+	url             string
+	client          *http.Client
+	refreshInterval time.Duration
+	keys            map[string]crypto.PublicKey
+	fetchedAt       time.Time     // Time of the last successful fetch
+	attemptedAt     time.Time     // Time of the last fetch, successful or not
+	failures        int           // Fetches failed since the last successful one
+	fetchErr        error         // Error of the last fetch, nil if it succeeded
+	fetching        chan struct{} // Closed when the fetch in progress ends, nil if none
+	m               sync.Mutex
+}
+
+// minRefreshInterval limits how often unknown key IDs can trigger a fetch of the JWKS document.
+const minRefreshInterval = 30 * time.Second
+
+// maxFetchBackoff is the longest wait before fetching the JWKS document again after failures.
+const maxFetchBackoff = time.Minute
+
+func NewRemoteKeySet(url string, refreshInterval time.Duration) *RemoteKeySet {
+	return &RemoteKeySet{
+		url:             url,
+		client:          &http.Client{Timeout: 10 * time.Second},
+		refreshInterval: refreshInterval,
+	}
+}
+
+func (r *RemoteKeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	r.m.Lock()
+	keys, fetchedAt := r.keys, r.fetchedAt
+	r.m.Unlock()
+
+	if keys != nil {
+		// Refreshing stale keys in the background, they are served meanwhile
+		if time.Since(fetchedAt) > r.refreshInterval {
+			r.startFetch(0)
+		}
+		key, err := staticKeySet(keys).Key(ctx, kid)
+		if !errors.Is(err, ErrKeyNotFound) {
+			return key, err
+		}
+	}
+
+	// No keys yet, or an unknown key that may have been rotated in, waiting for a new document
+	minInterval := minRefreshInterval
+	if keys == nil {
+		minInterval = 0
+	}
+	if done := r.startFetch(minInterval); done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	r.m.Lock()
+	keys, fetchErr := r.keys, r.fetchErr
+	r.m.Unlock()
+	if keys == nil {
+		if fetchErr == nil {
+			fetchErr = errors.New("failed to fetch JWKS: no keys fetched yet")
+		}
+		return nil, fetchErr
+	}
+	// The key is unknown even if the document couldn't be fetched again, the token is at fault
+	return staticKeySet(keys).Key(ctx, kid)
+}
+
+// startFetch starts fetching the JWKS document unless the last fetch is more recent than minInterval,
+// or than the backoff after failed fetches. It returns a channel closed once the fetch ends,
+// the one of the fetch in progress if any, or nil if no fetch is started.
+func (r *RemoteKeySet) startFetch(minInterval time.Duration) <-chan struct{} {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if r.fetching != nil {
+		return r.fetching
+	}
+	if r.failures > 0 {
+		minInterval = max(minInterval, min(time.Second<<min(r.failures-1, 6), maxFetchBackoff))
+	}
+	if !r.attemptedAt.IsZero() && time.Since(r.attemptedAt) < minInterval {
+		return nil
+	}
+
+	done := make(chan struct{})
+	r.fetching = done
+	r.attemptedAt = time.Now()
+	go func() {
+		// Fetching for all the waiting requests, independently of any of them
+		keys, err := r.fetch(context.Background())
+
+		r.m.Lock()
+		defer r.m.Unlock()
+		if err != nil {
+			r.failures++
+		} else {
+			r.keys = keys
+			r.fetchedAt = time.Now()
+			r.failures = 0
+		}
+		r.fetchErr = err
+		r.fetching = nil
+		close(done)
+	}()
+	return done
+}
+
+// fetch downloads and parses the JWKS document.
+func (r *RemoteKeySet) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS: %w", err)
+	}
+	return parseJWKS(data)
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStaticKeySetKey(t *testing.T) {
+	first, _, _ := ed25519.GenerateKey(nil)
+	second, _, _ := ed25519.GenerateKey(nil)
+
+	tests := []struct {
+		name    string
+		keys    staticKeySet
+		kid     string
+		want    crypto.PublicKey
+		wantErr error
+	}{
+		{name: "key by ID", keys: staticKeySet{"a": first, "b": second}, kid: "b", want: second},
+		{name: "only key without token ID", keys: staticKeySet{"a": first}, kid: "", want: first},
+		{name: "unknown ID", keys: staticKeySet{"a": first, "b": second}, kid: "c", wantErr: ErrKeyNotFound},
+		{name: "no token ID with several keys", keys: staticKeySet{"a": first, "b": second}, kid: "", wantErr: ErrKeyNotFound},
+		{name: "key without ID matches any ID", keys: staticKeySet{"": first}, kid: "rotated", want: first},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := tt.keys.Key(context.Background(), tt.kid)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Key(%q) error = %v, want %v", tt.kid, err, tt.wantErr)
+			}
+			if tt.want != nil && !tt.want.(ed25519.PublicKey).Equal(key) {
+				t.Fatalf("Key(%q) returned another key", tt.kid)
+			}
+		})
+	}
+}
+
+// jwksServer serves a JWKS document with a single Ed25519 key, failing once down is set.
+func jwksServer(t *testing.T, kid string, key ed25519.PublicKey, down *atomic.Bool, fetches *atomic.Int32) *httptest.Server {
+	t.Helper()
+	doc := fmt.Sprintf(`{"keys": [{"kid": %q, "kty": "OKP", "crv": "Ed25519", "x": %q}]}`, kid, base64.RawURLEncoding.EncodeToString(key))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, doc)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRemoteKeySetServesCachedKeysWhileDown(t *testing.T) {
+	key, _, _ := ed25519.GenerateKey(nil)
+	var down atomic.Bool
+	var fetches atomic.Int32
+	server := jwksServer(t, "k1", key, &down, &fetches)
+	keys := NewRemoteKeySet(server.URL, time.Millisecond)
+	ctx := context.Background()
+
+	if _, err := keys.Key(ctx, "k1"); err != nil {
+		t.Fatalf("Key(k1) error = %v", err)
+	}
+
+	down.Store(true)
+	time.Sleep(5 * time.Millisecond)
+	for range 10 {
+		got, err := keys.Key(ctx, "k1")
+		if err != nil || !key.Equal(got) {
+			t.Fatalf("Key(k1) while down = %v, %v, want the cached key", got, err)
+		}
+	}
+	// An unknown key is the token's fault, even if the keys can't be fetched again
+	if _, err := keys.Key(ctx, "unknown"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Key(unknown) while down error = %v, want %v", err, ErrKeyNotFound)
+	}
+	// Failed fetches back off instead of being retried by every request
+	if n := fetches.Load(); n > 3 {
+		t.Fatalf("JWKS fetched %d times, want fetches to back off", n)
+	}
+}
+
+func TestRemoteKeySetFetchesOnce(t *testing.T) {
+	key, _, _ := ed25519.GenerateKey(nil)
+	var down atomic.Bool
+	var fetches atomic.Int32
+	server := jwksServer(t, "k1", key, &down, &fetches)
+	keys := NewRemoteKeySet(server.URL, time.Hour)
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := keys.Key(context.Background(), "k1"); err != nil {
+				t.Errorf("Key(k1) error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", n)
+	}
+}
+
+func TestRemoteKeySetFailsWithoutKeys(t *testing.T) {
+	key, _, _ := ed25519.GenerateKey(nil)
+	var down atomic.Bool
+	var fetches atomic.Int32
+	down.Store(true)
+	server := jwksServer(t, "k1", key, &down, &fetches)
+	keys := NewRemoteKeySet(server.URL, time.Hour)
+
+	_, err := keys.Key(context.Background(), "k1")
+	if err == nil || errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Key(k1) error = %v, want a fetch error", err)
+	}
+}
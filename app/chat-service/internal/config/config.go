@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
 	"strings"
@@ -22,8 +23,49 @@ type Config struct {
 	AMQPHost                 string
 	MsgQueue                 string
 	MessageReaderServiceAddr string
+	TypingTimeout            time.Duration // Duration after which a silent typing state expires
+	InstanceID               string        // Unique ID of this replica, defaults to the hostname
+	PresenceTTL              time.Duration // Duration after which a replica's presence entries expire without heartbeat
+	PresenceHeartbeat        time.Duration // Interval between presence heartbeats, must be lower than PresenceTTL
+	RoutingMode              string        // "user" for a Redis channel per user, "replica" for a channel per replica and a route registry, "stream" for a Redis stream per user
+	RouteTTL                 time.Duration // Duration after which the routes to a replica expire without heartbeat
+	RouteHeartbeat           time.Duration // Interval between route heartbeats, must be lower than RouteTTL
+	StreamMaxLen             int64         // Approximate number of entries kept in the stream of a user
+	StreamTTL                time.Duration // Duration the stream and delivery offset of a user are kept after their last update
+	DedupeTTL                time.Duration // Duration during which client message IDs are remembered to drop retried sends
+	WSWriteWait              time.Duration // Time allowed to write a frame to a WebSocket
+	WSPongWait               time.Duration // Time allowed to read the next pong or frame from a WebSocket
+	WSPingPeriod             time.Duration // Interval between pings, must be lower than WSPongWait
+	WSSendQueueSize          int           // Frames queued for a WebSocket before it is closed as too slow
+	WSMaxMessageSize         int64         // Largest frame read from a WebSocket, larger ones close the connection
+	ResumeMaxMessages        int           // Maximum number of messages replayed per conversation when a client resumes
+	SSEKeepalive             time.Duration // Interval between keepalive comments of Server-Sent Events streams
+	PollTimeout              time.Duration // Time a long poll waits for events before returning none
+	PollSessionTTL           time.Duration // Duration after which a long polling session expires without polls
+	DrainWindow              time.Duration // Duration over which connections are closed when shutting down
+	ShutdownTimeout          time.Duration // Time left after the drain window for handlers to finish before closing dependencies
+	AuthKeyFile              string        // Local PEM public key or JWKS document used to verify tokens
+	AuthJWKSURL              string        // JWKS endpoint used to verify tokens, takes precedence over AuthKeyFile
+	AuthJWKSRefreshInterval  time.Duration // Interval after which the JWKS document is fetched again
+	AuthIssuer               string        // Expected "iss" claim, not checked if empty
+	AuthAudience             string        // Expected "aud" claim, not checked if empty
+	AttachmentStore          string        // Blob store backend of attachments, only "local" for now
+	AttachmentDir            string        // Directory of the local attachment store, on a volume shared by replicas
+	AttachmentMaxSize        int64         // Maximum size of an attachment in bytes
 	Env                      string
 	CORSConfig               cors.Config
+
+	RateLimitEvents       map[string]RateLimit // Events of each type a user can send, types left out are not limited
+	RateLimitConnections  RateLimit            // Connection attempts from a client IP
+	RateLimitViolations   RateLimit            // Rate limited events a user can send before being disconnected
+	MaxConnectionsPerUser int                  // Concurrent WebSockets, event streams and poll sessions of a user, 0 for no limit
+	TrustedProxies        []string             // Proxies whose X-Forwarded-For header gives the client IP, none if empty
+}
+
+// RateLimit allows Count actions per Period, in bursts of up to Count actions. A zero Count disables the limit.
+type RateLimit struct {
+	Count  int
+	Period time.Duration
 }
 
 var (
@@ -44,6 +86,129 @@ func Get() (*Config, error) {
 		if err != nil {
 			return
 		}
+		var typingTimeout time.Duration
+		typingTimeout, err = time.ParseDuration(getEnv("TYPING_TIMEOUT", "5s"))
+		if err != nil {
+			return
+		}
+		var presenceTTL, presenceHeartbeat time.Duration
+		presenceTTL, err = time.ParseDuration(getEnv("PRESENCE_TTL", "30s"))
+		if err != nil {
+			return
+		}
+		presenceHeartbeat, err = time.ParseDuration(getEnv("PRESENCE_HEARTBEAT_INTERVAL", "10s"))
+		if err != nil {
+			return
+		}
+		var routeTTL, routeHeartbeat time.Duration
+		routeTTL, err = time.ParseDuration(getEnv("ROUTE_TTL", "30s"))
+		if err != nil {
+			return
+		}
+		routeHeartbeat, err = time.ParseDuration(getEnv("ROUTE_HEARTBEAT_INTERVAL", "10s"))
+		if err != nil {
+			return
+		}
+		var streamMaxLen int64
+		streamMaxLen, err = strconv.ParseInt(getEnv("STREAM_MAX_LEN", "1000"), 10, 64)
+		if err != nil {
+			return
+		}
+		var streamTTL time.Duration
+		streamTTL, err = time.ParseDuration(getEnv("STREAM_TTL", "5m"))
+		if err != nil {
+			return
+		}
+		var jwksRefreshInterval time.Duration
+		jwksRefreshInterval, err = time.ParseDuration(getEnv("AUTH_JWKS_REFRESH_INTERVAL", "1h"))
+		if err != nil {
+			return
+		}
+		var dedupeTTL time.Duration
+		dedupeTTL, err = time.ParseDuration(getEnv("DEDUPE_TTL", "1h"))
+		if err != nil {
+			return
+		}
+		var wsWriteWait, wsPongWait, wsPingPeriod time.Duration
+		wsWriteWait, err = time.ParseDuration(getEnv("WS_WRITE_WAIT", "10s"))
+		if err != nil {
+			return
+		}
+		wsPongWait, err = time.ParseDuration(getEnv("WS_PONG_WAIT", "60s"))
+		if err != nil {
+			return
+		}
+		// Pinging early enough for the pong to arrive before the read deadline
+		wsPingPeriod, err = time.ParseDuration(getEnv("WS_PING_PERIOD", (wsPongWait * 9 / 10).String()))
+		if err != nil {
+			return
+		}
+		var wsSendQueueSize int
+		wsSendQueueSize, err = strconv.Atoi(getEnv("WS_SEND_QUEUE_SIZE", "256"))
+		if err != nil {
+			return
+		}
+		var wsMaxMessageSize int64
+		wsMaxMessageSize, err = strconv.ParseInt(getEnv("WS_MAX_MESSAGE_SIZE", "65536"), 10, 64)
+		if err != nil {
+			return
+		}
+		var resumeMaxMessages int
+		resumeMaxMessages, err = strconv.Atoi(getEnv("RESUME_MAX_MESSAGES", "500"))
+		if err != nil {
+			return
+		}
+		var sseKeepalive, pollTimeout, pollSessionTTL time.Duration
+		sseKeepalive, err = time.ParseDuration(getEnv("SSE_KEEPALIVE_INTERVAL", "15s"))
+		if err != nil {
+			return
+		}
+		pollTimeout, err = time.ParseDuration(getEnv("POLL_TIMEOUT", "25s"))
+		if err != nil {
+			return
+		}
+		pollSessionTTL, err = time.ParseDuration(getEnv("POLL_SESSION_TTL", "60s"))
+		if err != nil {
+			return
+		}
+		var drainWindow, shutdownTimeout time.Duration
+		drainWindow, err = time.ParseDuration(getEnv("DRAIN_WINDOW", "15s"))
+		if err != nil {
+			return
+		}
+		shutdownTimeout, err = time.ParseDuration(getEnv("SHUTDOWN_TIMEOUT", "10s"))
+		if err != nil {
+			return
+		}
+		var rateLimitEvents map[string]RateLimit
+		rateLimitEvents, err = parseEventRateLimits(getEnv("RATE_LIMIT_EVENTS", "Message=30/10s,MessageEvent=100/10s,MessageEdit=20/10s,MessageDelete=20/10s"))
+		if err != nil {
+			return
+		}
+		var rateLimitConnections, rateLimitViolations RateLimit
+		rateLimitConnections, err = parseRateLimit(getEnv("RATE_LIMIT_CONNECTIONS", "60/1m"))
+		if err != nil {
+			return
+		}
+		rateLimitViolations, err = parseRateLimit(getEnv("RATE_LIMIT_VIOLATIONS", "10/1m"))
+		if err != nil {
+			return
+		}
+		var maxConnectionsPerUser int
+		maxConnectionsPerUser, err = strconv.Atoi(getEnv("MAX_CONNECTIONS_PER_USER", "10"))
+		if err != nil {
+			return
+		}
+		var trustedProxies []string
+		if proxies := getEnv("TRUSTED_PROXIES", ""); proxies != "" {
+			trustedProxies = strings.Split(proxies, ",")
+		}
+		var attachmentMaxSize int64
+		attachmentMaxSize, err = strconv.ParseInt(getEnv("ATTACHMENT_MAX_SIZE", "26214400"), 10, 64)
+		if err != nil {
+			return
+		}
+		hostname, _ := os.Hostname()
 		instance = &Config{
 			Port:                     getEnv("PORT", "8080"),
 			RedisAddr:                getEnv("REDIS_ADDR", "localhost:6379"),
@@ -55,6 +220,40 @@ func Get() (*Config, error) {
 			AMQPHost:                 getEnv("AMQP_HOST", ""),
 			MsgQueue:                 getEnv("MSG_QUEUE", "messages"),
 			MessageReaderServiceAddr: getEnv("MESSAGE_READER_SERVICE_ADDR", "localhost:50051"),
+			TypingTimeout:            typingTimeout,
+			InstanceID:               getEnv("INSTANCE_ID", hostname),
+			PresenceTTL:              presenceTTL,
+			PresenceHeartbeat:        presenceHeartbeat,
+			RoutingMode:              getEnv("ROUTING_MODE", "user"),
+			RouteTTL:                 routeTTL,
+			RouteHeartbeat:           routeHeartbeat,
+			StreamMaxLen:             streamMaxLen,
+			StreamTTL:                streamTTL,
+			DedupeTTL:                dedupeTTL,
+			WSWriteWait:              wsWriteWait,
+			WSPongWait:               wsPongWait,
+			WSPingPeriod:             wsPingPeriod,
+			WSSendQueueSize:          wsSendQueueSize,
+			WSMaxMessageSize:         wsMaxMessageSize,
+			ResumeMaxMessages:        resumeMaxMessages,
+			SSEKeepalive:             sseKeepalive,
+			PollTimeout:              pollTimeout,
+			PollSessionTTL:           pollSessionTTL,
+			DrainWindow:              drainWindow,
+			ShutdownTimeout:          shutdownTimeout,
+			RateLimitEvents:          rateLimitEvents,
+			RateLimitConnections:     rateLimitConnections,
+			RateLimitViolations:      rateLimitViolations,
+			MaxConnectionsPerUser:    maxConnectionsPerUser,
+			TrustedProxies:           trustedProxies,
+			AuthKeyFile:              getEnv("AUTH_KEY_FILE", ""),
+			AuthJWKSURL:              getEnv("AUTH_JWKS_URL", ""),
+			AuthJWKSRefreshInterval:  jwksRefreshInterval,
+			AuthIssuer:               getEnv("AUTH_ISSUER", ""),
+			AuthAudience:             getEnv("AUTH_AUDIENCE", ""),
+			AttachmentStore:          getEnv("ATTACHMENT_STORE", "local"),
+			AttachmentDir:            getEnv("ATTACHMENT_DIR", ""),
+			AttachmentMaxSize:        attachmentMaxSize,
 			CORSConfig:               setupCORS("CORS_ALLOWED_ORIGINS"),
 			Env:                      getEnv("ENV", "development"),
 		}
@@ -71,6 +270,46 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// parseRateLimit parses a limit written "{count}/{period}", e.g. "20/10s", or "0" for no limit.
+func parseRateLimit(value string) (RateLimit, error) {
+	if value == "0" {
+		return RateLimit{}, nil
+	}
+	count, period, found := strings.Cut(value, "/")
+	if !found {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q", value)
+	}
+	var limit RateLimit
+	var err error
+	if limit.Count, err = strconv.Atoi(count); err != nil || limit.Count < 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q", value)
+	}
+	if limit.Period, err = time.ParseDuration(period); err != nil || limit.Period <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q", value)
+	}
+	return limit, nil
+}
+
+// parseEventRateLimits parses the limits of event types written "{type}={count}/{period},...", e.g. "Message=20/10s".
+func parseEventRateLimits(value string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	if value == "" {
+		return limits, nil
+	}
+	for _, entry := range strings.Split(value, ",") {
+		eventType, limit, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if !found {
+			return nil, fmt.Errorf("invalid event rate limit %q", entry)
+		}
+		parsed, err := parseRateLimit(limit)
+		if err != nil {
+			return nil, err
+		}
+		limits[eventType] = parsed
+	}
+	return limits, nil
+}
+
 // setupCORS configures CORS settings based on environment variables.
 func setupCORS(corsEnvVar string) cors.Config {
 
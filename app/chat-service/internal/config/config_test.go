@@ -0,0 +1,39 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    RateLimit
+		wantErr bool
+	}{
+		{name: "count per period", value: "20/10s", want: RateLimit{Count: 20, Period: 10 * time.Second}},
+		{name: "count per minute", value: "60/1m", want: RateLimit{Count: 60, Period: time.Minute}},
+		{name: "no limit", value: "0", want: RateLimit{}},
+		{name: "zero count", value: "0/1m", want: RateLimit{Period: time.Minute}},
+		{name: "missing period", value: "20", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+		{name: "negative count", value: "-1/1m", wantErr: true},
+		{name: "invalid count", value: "many/1m", wantErr: true},
+		{name: "invalid period", value: "20/soon", wantErr: true},
+		{name: "period without unit", value: "20/10", wantErr: true},
+		{name: "zero period", value: "20/0s", wantErr: true},
+		{name: "negative period", value: "20/-1s", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRateLimit(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRateLimit(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("parseRateLimit(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
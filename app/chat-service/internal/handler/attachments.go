@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/middleware"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/model"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/service"
+	"github.com/nsmsb/darda-chat/app/chat-service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// maxMessageAttachments is the maximum number of attachments of a message.
+const maxMessageAttachments = 10
+
+// resolveAttachments replaces the attachments of a message by their stored metadata.
+// Only attachments uploaded by the sender to the conversation of the message can be sent.
+func (handler *MessageHandler) resolveAttachments(ctx context.Context, msg *model.Message) error {
+	for i, attachment := range msg.Attachments {
+		stored, err := handler.attachmentService.GetAttachment(ctx, attachment.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get attachment: %w", err)
+		}
+		if stored.ConversationID != msg.ConversationID || stored.UploadedBy != msg.Sender {
+			return invalidEvent("invalid message: attachment %s was not uploaded by the sender to the conversation", attachment.ID)
+		}
+		msg.Attachments[i] = stored.Attachment
+	}
+	return nil
+}
+
+// UploadAttachment handles HTTP requests uploading a file to a conversation, to be attached to messages sent to it.
+// The file is the "file" part of a multipart form, streamed into the attachment store.
+func (handler *MessageHandler) UploadAttachment(c *gin.Context) {
+	// Prepare logger from context
+	log := logger.GetFromContext(c)
+	ctx := c.Request.Context()
+
+	// Get uploader from the token, user (or group conversation ID) from query and resolve conversation ID
+	userId := c.GetString(middleware.UserIDKey)
+	destination := c.Query("to")
+	if destination == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "missing destination",
+		})
+		return
+	}
+	conversationID, ok := resolveConversation(c, ctx, handler.messageReaderService, userId, destination)
+	if !ok {
+		return
+	}
+
+	// Finding the file part without buffering the form
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid multipart form",
+		})
+		return
+	}
+	var file io.Reader
+	var name string
+	for file == nil {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "missing file",
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid multipart form",
+			})
+			return
+		}
+		if part.FormName() == "file" {
+			file, name = part, part.FileName()
+		}
+	}
+
+	attachment, err := handler.attachmentService.Upload(ctx, conversationID, userId, name, file)
+	if errors.Is(err, service.ErrAttachmentTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": "attachment too large",
+		})
+		return
+	}
+	if err != nil {
+		log.Error("Failed to upload attachment", zap.String("user_id", userId), zap.String("conversation", conversationID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to upload attachment",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, attachment)
+}
+
+// GetAttachment handles HTTP requests to download an attachment, only participants of its conversation can fetch it.
+func (handler *MessageHandler) GetAttachment(c *gin.Context) {
+	// Prepare logger from context
+	log := logger.GetFromContext(c)
+	ctx := c.Request.Context()
+
+	userId := c.GetString(middleware.UserIDKey)
+	attachmentID := c.Param("attachment")
+
+	attachment, err := handler.attachmentService.GetAttachment(ctx, attachmentID)
+	if errors.Is(err, service.ErrAttachmentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "attachment not found",
+		})
+		return
+	}
+	if err != nil {
+		log.Error("Failed to get attachment", zap.String("attachment_id", attachmentID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get attachment",
+		})
+		return
+	}
+
+	members, err := handler.conversationMembers(ctx, attachment.ConversationID)
+	if err != nil {
+		log.Error("Failed to get attachment participants", zap.String("conversation", attachment.ConversationID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get attachment",
+		})
+		return
+	}
+	if !slices.Contains(members, userId) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "not a participant of the conversation",
+		})
+		return
+	}
+
+	content, err := handler.attachmentService.Open(ctx, attachmentID)
+	if err != nil {
+		log.Error("Failed to open attachment", zap.String("attachment_id", attachmentID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get attachment",
+		})
+		return
+	}
+	defer content.Close()
+
+	// Images are shown inline, other files downloaded, and browsers must not guess another type
+	disposition := "attachment"
+	if strings.HasPrefix(attachment.MimeType, "image/") {
+		disposition = "inline"
+	}
+	if attachment.Name != "" {
+		if value := mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Name}); value != "" {
+			disposition = value
+		}
+	}
+	headers := map[string]string{
+		"Content-Disposition":    disposition,
+		"X-Content-Type-Options": "nosniff",
+		"Cache-Control":          "private, max-age=86400",
+		"ETag":                   fmt.Sprintf("%q", attachment.Checksum),
+	}
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.MimeType, content, headers)
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/config"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/middleware"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/model"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/service"
+	"github.com/nsmsb/darda-chat/app/chat-service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type BlockHandler struct {
+	publisher            service.Publisher
+	messageReaderService service.MessageReader
+}
+
+func NewBlockHandler(publisher service.Publisher, messageReaderService service.MessageReader) *BlockHandler {
+	return &BlockHandler{
+		publisher:            publisher,
+		messageReaderService: messageReaderService,
+	}
+}
+
+// BlockUser handles HTTP requests to block a user.
+// The block is persisted asynchronously by the message-writer-service.
+func (handler *BlockHandler) BlockUser(c *gin.Context) {
+	userId := c.GetString(middleware.UserIDKey)
+
+	var block model.Block
+	if err := c.ShouldBindJSON(&block); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid block",
+		})
+		return
+	}
+	if block.BlockedUserID == userId {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "users can't block themselves",
+		})
+		return
+	}
+
+	block.UserID = userId
+	block.Blocked = true
+	handler.publishBlock(c, block)
+}
+
+// UnblockUser handles HTTP requests to unblock a user, unblocking a user who isn't blocked does nothing.
+func (handler *BlockHandler) UnblockUser(c *gin.Context) {
+	userId := c.GetString(middleware.UserIDKey)
+
+	blockedUserID := c.Param("user")
+	if blockedUserID == userId {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "users can't unblock themselves",
+		})
+		return
+	}
+
+	handler.publishBlock(c, model.Block{
+		UserID:        userId,
+		BlockedUserID: blockedUserID,
+		Blocked:       false,
+	})
+}
+
+// publishBlock publishes a block or unblock to the message queue to be persisted, and writes the response.
+func (handler *BlockHandler) publishBlock(c *gin.Context, block model.Block) {
+	// Prepare logger from context
+	log := logger.GetFromContext(c)
+
+	// The writer keeps the latest change between two users, ordered by this timestamp
+	block.UpdatedAt = time.Now().UTC()
+
+	event, err := marshalBlockEvent(block)
+	if err != nil {
+		log.Error("Failed to marshal block", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to update block",
+		})
+		return
+	}
+	config, _ := config.Get()
+	if err := handler.publisher.Publish(c.Request.Context(), string(event), config.MsgQueue); err != nil {
+		log.Error("Failed to publish block", zap.String("blocked_user_id", block.BlockedUserID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to update block",
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, block)
+}
+
+// marshalBlockEvent wraps a block in a Block event.
+func marshalBlockEvent(block model.Block) ([]byte, error) {
+	content, err := json.Marshal(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal block: %w", err)
+	}
+	return json.Marshal(model.Event{
+		Type:      model.EventTypeBlock,
+		EventID:   uuid.New().String(),
+		Timestamp: block.UpdatedAt,
+		Content:   content,
+	})
+}
+
+// ListBlocks handles HTTP requests to list the users the user blocked.
+func (handler *BlockHandler) ListBlocks(c *gin.Context) {
+	// Prepare logger from context
+	log := logger.GetFromContext(c)
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	userId := c.GetString(middleware.UserIDKey)
+	blocks, err := handler.messageReaderService.ListBlocks(ctx, userId)
+	if err != nil {
+		log.Error("Failed to list blocks", zap.String("user_id", userId), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to list blocks",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"blocks": blocks,
+	})
+}
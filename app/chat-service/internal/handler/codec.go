@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/model"
+)
+
+const (
+	// WebSocket subprotocols, JSON being used for clients asking for none
+	subprotocolJSON  = "darda.v1.json"
+	subprotocolProto = "darda.v1.proto"
+)
+
+// eventCodec translates between the JSON events handlers and services exchange, and the frames of a subprotocol.
+type eventCodec interface {
+	// Decode reads an event from a frame sent by the client.
+	Decode(data []byte) (model.Event, error)
+	// Encode turns a JSON event into a frame for the client.
+	Encode(data []byte) ([]byte, error)
+	// FrameType returns the type of the WebSocket frames written to the client.
+	FrameType() int
+}
+
+// negotiateCodec returns the first subprotocol requested by the client that is supported, and its codec.
+// It fails if the client only requested unknown subprotocols.
+func negotiateCodec(r *http.Request) (string, eventCodec, bool) {
+	requested := websocket.Subprotocols(r)
+	if len(requested) == 0 {
+		return "", jsonCodec{}, true
+	}
+	for _, subprotocol := range requested {
+		switch subprotocol {
+		case subprotocolJSON:
+			return subprotocol, jsonCodec{}, true
+		case subprotocolProto:
+			return subprotocol, protoCodec{}, true
+		}
+	}
+	return "", nil, false
+}
+
+// jsonCodec exchanges events as JSON in text frames, as handlers and services do.
+type jsonCodec struct{}
+
+func (jsonCodec) Decode(data []byte) (model.Event, error) {
+	var event model.Event
+	err := json.Unmarshal(data, &event)
+	return event, err
+}
+
+func (jsonCodec) Encode(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+func (jsonCodec) FrameType() int {
+	return websocket.TextMessage
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/config"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/middleware"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/model"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/service"
+	"github.com/nsmsb/darda-chat/app/chat-service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type ConversationHandler struct {
+	publisher            service.Publisher
+	messageReaderService service.MessageReader
+}
+
+func NewConversationHandler(publisher service.Publisher, messageReaderService service.MessageReader) *ConversationHandler {
+	return &ConversationHandler{
+		publisher:            publisher,
+		messageReaderService: messageReaderService,
+	}
+}
+
+// ListConversations handles HTTP requests to list the conversations of the user, most recently active first.
+func (handler *ConversationHandler) ListConversations(c *gin.Context) {
+	// Prepare logger from context
+	log := logger.GetFromContext(c)
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	userId := c.GetString(middleware.UserIDKey)
+	before := c.Query("before")
+
+	page, err := handler.messageReaderService.ListConversations(ctx, userId, before)
+	if err != nil {
+		log.Error("Failed to list conversations", zap.String("user_id", userId), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to list conversations",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"conversations": page.Conversations,
+		"before":        page.Before,
+	})
+}
+
+// GetNotificationSettings handles HTTP requests to retrieve the notification settings of the user for a conversation.
+func (handler *ConversationHandler) GetNotificationSettings(c *gin.Context) {
+	// Prepare logger from context
+	log := logger.GetFromContext(c)
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	userId := c.GetString(middleware.UserIDKey)
+	conversationID, ok := resolveConversation(c, ctx, handler.messageReaderService, userId, c.Param("user"))
+	if !ok {
+		return
+	}
+
+	settings, err := handler.messageReaderService.GetNotificationSettings(ctx, userId, conversationID)
+	if err != nil {
+		log.Error("Failed to get notification settings", zap.String("conversation", conversationID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get notification settings",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateNotificationSettings handles HTTP requests to change the notification settings of the user for a conversation.
+// The settings are persisted asynchronously by the message-writer-service.
+func (handler *ConversationHandler) UpdateNotificationSettings(c *gin.Context) {
+	// Prepare logger from context
+	log := logger.GetFromContext(c)
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var settings model.NotificationSettings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid notification settings",
+		})
+		return
+	}
+	// Only muted conversations have a mute time, it must be in the future
+	if settings.Level != model.NotifyMuted {
+		settings.MutedUntil = time.Time{}
+	} else if !settings.MutedUntil.IsZero() && !settings.MutedUntil.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "muted_until must be in the future",
+		})
+		return
+	}
+
+	userId := c.GetString(middleware.UserIDKey)
+	conversationID, ok := resolveConversation(c, ctx, handler.messageReaderService, userId, c.Param("user"))
+	if !ok {
+		return
+	}
+	settings.UserID = userId
+	settings.ConversationID = conversationID
+	settings.MutedUntil = settings.MutedUntil.UTC()
+	// The writer keeps the latest change of a conversation, ordered by this timestamp
+	settings.UpdatedAt = time.Now().UTC()
+
+	// Publishing the settings to the message queue to be persisted
+	content, err := json.Marshal(settings)
+	if err != nil {
+		log.Error("Failed to marshal notification settings", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to update notification settings",
+		})
+		return
+	}
+	event, err := json.Marshal(model.Event{
+		Type:      model.EventTypeNotificationSettings,
+		EventID:   uuid.New().String(),
+		Timestamp: settings.UpdatedAt,
+		Content:   content,
+	})
+	if err != nil {
+		log.Error("Failed to marshal event", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to update notification settings",
+		})
+		return
+	}
+	config, _ := config.Get()
+	if err := handler.publisher.Publish(ctx, string(event), config.MsgQueue); err != nil {
+		log.Error("Failed to publish notification settings", zap.String("conversation", conversationID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to update notification settings",
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, settings)
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Drainer tracks the long-lived connections of the replica, WebSockets, event streams and poll sessions,
+// so they can be closed gradually when it shuts down and their clients reconnect to other replicas.
+type Drainer struct {
+	m        sync.Mutex
+	draining bool
+	closers  map[uint64]func() // Closing functions of the tracked connections, by tracking ID
+	nextID   uint64
+	wg       sync.WaitGroup // Tracked connections not closed yet
+}
+
+func NewDrainer() *Drainer {
+	return &Drainer{
+		closers: make(map[uint64]func()),
+	}
+}
+
+// Draining reports whether the replica is shutting down, new connections being refused.
+func (d *Drainer) Draining() bool {
+	d.m.Lock()
+	defer d.m.Unlock()
+	return d.draining
+}
+
+// Track registers a connection, closeConn being called to close it when draining, and returns the function
+// to call once the connection is closed. It fails if the replica is draining, the connection must then be refused.
+func (d *Drainer) Track(closeConn func()) (func(), bool) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	if d.draining {
+		return nil, false
+	}
+	id := d.nextID
+	d.nextID++
+	d.closers[id] = closeConn
+	d.wg.Add(1)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			d.m.Lock()
+			delete(d.closers, id)
+			d.m.Unlock()
+			d.wg.Done()
+		})
+	}, true
+}
+
+// Drain refuses new connections and closes the tracked ones, spread over the window so their clients don't all
+// reconnect at once. It returns once they are all closed, those left being closed at once if ctx is done first.
+func (d *Drainer) Drain(ctx context.Context, window time.Duration) error {
+	d.m.Lock()
+	d.draining = true
+	closers := make([]func(), 0, len(d.closers))
+	for _, closeConn := range d.closers {
+		closers = append(closers, closeConn)
+	}
+	d.m.Unlock()
+
+	if len(closers) > 0 {
+		interval := window / time.Duration(len(closers))
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+		for i, closeConn := range closers {
+			select {
+			case <-timer.C:
+				timer.Reset(interval)
+			case <-ctx.Done():
+				for _, closeConn := range closers[i:] {
+					closeConn()
+				}
+				return ctx.Err()
+			}
+			closeConn()
+		}
+	}
+
+	// Waiting for the handlers to be done with their connections
+	closed := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDrainerDrain(t *testing.T) {
+	tests := []struct {
+		name        string
+		connections int
+		window      time.Duration
+		timeout     time.Duration
+		untrack     bool // Whether closing a connection ends its handler
+		wantErr     error
+		minDuration time.Duration
+	}{
+		{name: "no connection", window: time.Second, timeout: time.Second},
+		{name: "connections closed over the window", connections: 4, window: 80 * time.Millisecond, timeout: time.Second, untrack: true, minDuration: 60 * time.Millisecond},
+		{name: "handlers not done", connections: 2, timeout: 50 * time.Millisecond, wantErr: context.DeadlineExceeded},
+		{name: "window longer than the timeout", connections: 3, window: time.Hour, timeout: 50 * time.Millisecond, untrack: true, wantErr: context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drainer := NewDrainer()
+			var closed atomic.Int32
+			for range tt.connections {
+				var untrack func()
+				untrack, ok := drainer.Track(func() {
+					closed.Add(1)
+					if tt.untrack {
+						untrack()
+					}
+				})
+				if !ok {
+					t.Fatal("Track refused a connection before draining")
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			start := time.Now()
+			err := drainer.Drain(ctx, tt.window)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Drain() error = %v, want %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed < tt.minDuration {
+				t.Fatalf("Drain() took %v, want connections spread over at least %v", elapsed, tt.minDuration)
+			}
+			// Connections left are closed at once when the drain is cut short
+			if n := int(closed.Load()); n != tt.connections {
+				t.Fatalf("closed %d connections, want %d", n, tt.connections)
+			}
+			if !drainer.Draining() {
+				t.Fatal("Draining() = false after Drain")
+			}
+			if _, ok := drainer.Track(func() {}); ok {
+				t.Fatal("Track accepted a connection while draining")
+			}
+		})
+	}
+}
+
+func TestDrainerUntrack(t *testing.T) {
+	drainer := NewDrainer()
+	var closed atomic.Int32
+	untrack, _ := drainer.Track(func() { closed.Add(1) })
+	// Untracking twice only counts once
+	untrack()
+	untrack()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := drainer.Drain(ctx, time.Second); err != nil {
+		t.Fatalf("Drain() error = %v", err)
+	}
+	if n := closed.Load(); n != 0 {
+		t.Fatalf("closed %d untracked connections", n)
+	}
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/model"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/service"
+)
+
+// errNotParticipant is returned when a user acts on a conversation they don't take part in.
+var errNotParticipant = errors.New("not a participant of the conversation")
+
+// eventError is an error caused by the client's event, reported back to it with its code and message.
+type eventError struct {
+	code       string
+	message    string
+	retryAfter time.Duration // Only for rate limited events
+}
+
+func (e *eventError) Error() string {
+	return e.message
+}
+
+// invalidEvent returns an error reporting a malformed event to the client.
+func invalidEvent(format string, args ...any) error {
+	return &eventError{code: model.ErrorCodeInvalidEvent, message: fmt.Sprintf(format, args...)}
+}
+
+// toErrorFrame maps an error from processing an event to the Error frame sent to the client.
+// Server side failures are not detailed to the client.
+func toErrorFrame(requestID string, err error) model.Error {
+	frame := model.Error{RequestID: requestID}
+	var evErr *eventError
+	switch {
+	case errors.As(err, &evErr):
+		frame.Code, frame.Message, frame.RetryAfter = evErr.code, evErr.message, evErr.retryAfter.Milliseconds()
+	case errors.Is(err, errNotParticipant):
+		frame.Code, frame.Message = model.ErrorCodeForbidden, errNotParticipant.Error()
+	case errors.Is(err, service.ErrGroupNotFound):
+		frame.Code, frame.Message = model.ErrorCodeNotFound, "group not found"
+	case errors.Is(err, service.ErrMessageNotFound):
+		frame.Code, frame.Message = model.ErrorCodeNotFound, "message not found"
+	case errors.Is(err, service.ErrAttachmentNotFound):
+		frame.Code, frame.Message = model.ErrorCodeNotFound, "attachment not found"
+	default:
+		frame.Code, frame.Message = model.ErrorCodeInternal, "internal error, please retry"
+	}
+	return frame
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/model"
+)
+
+// eventWriter delivers events to a client, through a WebSocket, an event stream or long polling.
+type eventWriter interface {
+	// WriteMessage queues a frame without waiting, the client is disconnected if it doesn't read its frames fast enough.
+	WriteMessage(data []byte) error
+	// Send queues a frame, waiting for room in the queue, for frames written faster than the client reads them.
+	Send(ctx context.Context, data []byte) error
+	// WriteFrame queues a frame as WriteMessage, calling its written function once it is written to the client.
+	WriteFrame(f frame) error
+	// SendFrame queues a frame as Send, calling its written function once it is written to the client.
+	SendFrame(ctx context.Context, f frame) error
+	// Done is closed when the client is disconnected.
+	Done() <-chan struct{}
+	// Disconnect closes the connection without waiting, the client reconnecting to receive its events again.
+	Disconnect()
+}
+
+// writeEvent wraps a frame in an event of the given type and queues it.
+func writeEvent(w eventWriter, eventType string, content any) error {
+	strEvent, err := marshalEvent(eventType, content)
+	if err != nil {
+		return err
+	}
+	return w.WriteMessage(strEvent)
+}
+
+// sendEvent wraps a frame in an event of the given type and waits for room in the queue.
+func sendEvent(ctx context.Context, w eventWriter, eventType string, content any) error {
+	strEvent, err := marshalEvent(eventType, content)
+	if err != nil {
+		return err
+	}
+	return w.Send(ctx, strEvent)
+}
+
+// marshalEvent wraps a frame in an event of the given type.
+func marshalEvent(eventType string, content any) ([]byte, error) {
+	strContent, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(model.Event{
+		Type:      eventType,
+		EventID:   uuid.New().String(),
+		Timestamp: time.Now().UTC(),
+		Content:   strContent,
+	})
+}
+
+// frame is a queued frame, with the function to call once it is written to the client, if any.
+// Frames dropped for not being supported by the client are reported as written, they would never be.
+type frame struct {
+	data    []byte
+	written func()
+}
+
+// sent reports the frame written to the client.
+func (f frame) sent() {
+	if f.written != nil {
+		f.written()
+	}
+}
+
+// eventQueue holds the frames of a client that is not connected through a WebSocket, until they are written to it.
+type eventQueue struct {
+	send      chan frame
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newEventQueue(size int) *eventQueue {
+	return &eventQueue{
+		send: make(chan frame, size),
+		done: make(chan struct{}),
+	}
+}
+
+func (q *eventQueue) WriteMessage(data []byte) error {
+	return q.WriteFrame(frame{data: data})
+}
+
+func (q *eventQueue) WriteFrame(f frame) error {
+	select {
+	case <-q.done:
+		return errSocketClosed
+	default:
+	}
+
+	select {
+	case q.send <- f:
+		return nil
+	case <-q.done:
+		return errSocketClosed
+	default:
+		q.Close()
+		return errQueueFull
+	}
+}
+
+func (q *eventQueue) Send(ctx context.Context, data []byte) error {
+	return q.SendFrame(ctx, frame{data: data})
+}
+
+func (q *eventQueue) SendFrame(ctx context.Context, f frame) error {
+	select {
+	case q.send <- f:
+		return nil
+	case <-q.done:
+		return errSocketClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *eventQueue) Done() <-chan struct{} {
+	return q.done
+}
+
+func (q *eventQueue) Disconnect() {
+	q.Close()
+}
+
+// Close disconnects the client, the frames left in the queue are dropped.
+func (q *eventQueue) Close() {
+	q.closeOnce.Do(func() {
+		close(q.done)
+	})
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/config"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/middleware"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/model"
+	"github.com/nsmsb/darda-chat/app/chat-service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// PostEvent handles HTTP requests sending an event, for clients that can't use WebSockets.
+// The event is processed as if received from a WebSocket, and answered with its Ack or Error frame.
+func (handler *MessageHandler) PostEvent(c *gin.Context) {
+	// Prepare logger from context
+	log := logger.GetFromContext(c)
+
+	userId := c.GetString(middleware.UserIDKey)
+
+	var event model.Event
+	if err := c.ShouldBindJSON(&event); err != nil {
+		log.Error("Failed to unmarshal event", zap.String("user_id", userId), zap.Error(err))
+		c.JSON(http.StatusBadRequest, toErrorFrame("", invalidEvent("malformed event")))
+		return
+	}
+
+	// The request ID only correlates the reply, it is never forwarded
+	requestID := event.RequestID
+	event.RequestID = ""
+
+	typing, release := handler.httpTypingTracker(userId)
+	ack, err := handler.processMessageEvent(c, event, userId, typing)
+	release()
+	if err != nil {
+		log.Error("Failed to process message event", zap.String("user_id", userId), zap.Error(err))
+		frame := toErrorFrame(requestID, err)
+		if frame.RetryAfter > 0 {
+			c.Header("Retry-After", retryAfterSeconds(time.Duration(frame.RetryAfter)*time.Millisecond))
+		}
+		c.JSON(errorStatus(frame.Code), frame)
+		return
+	}
+	ack.RequestID = requestID
+	c.JSON(http.StatusOK, ack)
+}
+
+// httpTypingState is the typing state of a user's events sent over HTTP, kept while some conversations are typing
+// or some requests of the user are processed.
+type httpTypingState struct {
+	*typingTracker
+	requests int // Requests of the user using the state
+}
+
+// httpTypingTracker returns the typing state of the user's events sent over HTTP, shared by all of the user's requests,
+// and the function to call once the request is processed.
+func (handler *MessageHandler) httpTypingTracker(userId string) (*typingTracker, func()) {
+	handler.httpTypingM.Lock()
+	defer handler.httpTypingM.Unlock()
+	state, exists := handler.httpTyping[userId]
+	if !exists {
+		config, _ := config.Get()
+		state = &httpTypingState{typingTracker: newTypingTracker(config.TypingTimeout)}
+		state.idle = func() {
+			handler.forgetHTTPTyping(userId, state)
+		}
+		handler.httpTyping[userId] = state
+	}
+	state.requests++
+	return state.typingTracker, func() {
+		handler.httpTypingM.Lock()
+		state.requests--
+		handler.httpTypingM.Unlock()
+		handler.forgetHTTPTyping(userId, state)
+	}
+}
+
+// forgetHTTPTyping drops the typing state of the user once no request uses it and no conversation is typing anymore.
+func (handler *MessageHandler) forgetHTTPTyping(userId string, state *httpTypingState) {
+	handler.httpTypingM.Lock()
+	defer handler.httpTypingM.Unlock()
+	if state.requests == 0 && state.Len() == 0 && handler.httpTyping[userId] == state {
+		delete(handler.httpTyping, userId)
+	}
+}
+
+// errorStatus maps the code of an Error frame to the HTTP status answering the event.
+func errorStatus(code string) int {
+	switch code {
+	case model.ErrorCodeInvalidEvent, model.ErrorCodeUnsupportedEvent:
+		return http.StatusBadRequest
+	case model.ErrorCodeForbidden:
+		return http.StatusForbidden
+	case model.ErrorCodeNotFound:
+		return http.StatusNotFound
+	case model.ErrorCodeRateLimited:
+		return http.StatusTooManyRequests
+	case model.ErrorCodeDeliveryFailed:
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/config"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/middleware"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/model"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/service"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/utils"
+	"github.com/nsmsb/darda-chat/app/chat-service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type GroupHandler struct {
+	publisher            service.Publisher
+	messageReaderService service.MessageReader
+}
+
+func NewGroupHandler(publisher service.Publisher, messageReaderService service.MessageReader) *GroupHandler {
+	return &GroupHandler{
+		publisher:            publisher,
+		messageReaderService: messageReaderService,
+	}
+}
+
+// CreateGroup handles HTTP requests to create a group conversation.
+// The group is persisted asynchronously by the message-writer-service.
+func (handler *GroupHandler) CreateGroup(c *gin.Context) {
+	// Prepare logger from context
+	log := logger.GetFromContext(c)
+
+	userId := c.GetString(middleware.UserIDKey)
+
+	var group model.Group
+	if err := c.ShouldBindJSON(&group); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group",
+		})
+		return
+	}
+
+	// The creator is always a member, duplicated and empty members are dropped
+	members := append(group.Members, userId)
+	members = slices.DeleteFunc(members, func(member string) bool { return member == "" })
+	slices.Sort(members)
+	group.Members = slices.Compact(members)
+	if len(group.Members) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "a group needs at least one other member",
+		})
+		return
+	}
+
+	group.ID = utils.GenerateGroupConvId()
+	group.CreatedBy = userId
+	group.CreatedAt = time.Now().UTC()
+
+	// Publishing the group to the message queue to be persisted
+	content, err := json.Marshal(group)
+	if err != nil {
+		log.Error("Failed to marshal group", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to create group",
+		})
+		return
+	}
+	event, err := json.Marshal(model.Event{
+		Type:      model.EventTypeGroup,
+		EventID:   uuid.New().String(),
+		Timestamp: group.CreatedAt,
+		Content:   content,
+	})
+	if err != nil {
+		log.Error("Failed to marshal event", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to create group",
+		})
+		return
+	}
+	config, _ := config.Get()
+	if err := handler.publisher.Publish(c.Request.Context(), string(event), config.MsgQueue); err != nil {
+		log.Error("Failed to publish group", zap.String("group_id", group.ID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to create group",
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, group)
+}
+
+// GetGroup handles HTTP requests to retrieve a group conversation, only members can see it.
+func (handler *GroupHandler) GetGroup(c *gin.Context) {
+	// Prepare logger from context
+	log := logger.GetFromContext(c)
+
+	userId := c.GetString(middleware.UserIDKey)
+	groupID := c.Param("group")
+
+	group, err := handler.messageReaderService.GetGroup(c.Request.Context(), groupID)
+	if errors.Is(err, service.ErrGroupNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "group not found",
+		})
+		return
+	}
+	if err != nil {
+		log.Error("Failed to get group", zap.String("group_id", groupID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get group",
+		})
+		return
+	}
+	if !slices.Contains(group.Members, userId) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "not a member of the group",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
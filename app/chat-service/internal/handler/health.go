@@ -8,12 +8,14 @@ import (
 )
 
 type HealthHandler struct {
-	client *redis.Client
+	client  *redis.Client
+	drainer *Drainer
 }
 
-func NewHealthHandler() *HealthHandler {
+func NewHealthHandler(drainer *Drainer) *HealthHandler {
 	config, _ := config.Get()
 	return &HealthHandler{
+		drainer: drainer,
 		client: redis.NewClient(&redis.Options{
 			Addr:     config.RedisAddr,
 			Password: config.RedisPass,
@@ -31,6 +33,14 @@ func (handler *HealthHandler) Liveness(c *gin.Context) {
 
 // Readiness checks if the service is ready to accept requests
 func (handler *HealthHandler) Readiness(c *gin.Context) {
+	// Not taking new connections while shutting down
+	if handler.drainer.Draining() {
+		c.JSON(503, gin.H{
+			"status": "draining",
+		})
+		return
+	}
+
 	// Pinging Redis to check readiness
 	_, err := handler.client.Ping(c).Result()
 	if err != nil {
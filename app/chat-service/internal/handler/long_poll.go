@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/config"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/middleware"
+	"github.com/nsmsb/darda-chat/app/chat-service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// maxPollEvents is the maximum number of events returned by a poll.
+const maxPollEvents = 100
+
+// pollSession queues the events of a long polling client between its polls, it expires if the client stops polling.
+type pollSession struct {
+	*eventQueue
+	id      string
+	userId  string
+	idle    *time.Timer // Expires the session once the client stopped polling for the session TTL
+	polling sync.Mutex  // Held by the poll in progress
+	untrack func()      // Stops draining the session once closed
+	release func()      // Frees the connection of the user once closed
+}
+
+// Poll handles long polling requests, for clients that can neither use WebSockets nor Server-Sent Events.
+// The first poll opens a session, accepting resume cursors and device as WebSockets do, whose ID is passed to the next polls.
+// Each poll waits for events up to the poll timeout, and returns those queued since the previous poll.
+func (handler *MessageHandler) Poll(c *gin.Context) {
+	// Prepare logger from context
+	log := logger.GetFromContext(c)
+	ctx := c.Request.Context()
+
+	userId := c.GetString(middleware.UserIDKey)
+	config, _ := config.Get()
+
+	var session *pollSession
+	if sessionID := c.Query("session"); sessionID != "" {
+		session = handler.getPollSession(sessionID, userId)
+		if session == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "poll session not found",
+			})
+			return
+		}
+	} else {
+		resume := c.QueryArray("resume")
+		if err := parseResumeCursors(resume); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		device := c.Query("device")
+		if err := parseDevice(device); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		// Limiting connection attempts and concurrent connections, a session holding a connection until closed
+		release, ok := handler.acquireConnection(c, userId)
+		if !ok {
+			return
+		}
+		session, ok = handler.openPollSession(userId, device, resume, release)
+		if !ok {
+			release()
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error": "shutting down",
+			})
+			return
+		}
+		log.Info("Poll session opened", zap.String("user_id", userId), zap.String("session", session.id))
+	}
+
+	if !session.polling.TryLock() {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "poll already in progress",
+		})
+		return
+	}
+	defer session.polling.Unlock()
+	// The session doesn't expire while polled
+	if !session.idle.Stop() {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "poll session not found",
+		})
+		return
+	}
+	defer session.idle.Reset(config.PollSessionTTL)
+
+	// Waiting for a first event, then taking those queued with it
+	frames := []frame{}
+	timeout := time.NewTimer(config.PollTimeout)
+	defer timeout.Stop()
+	select {
+	case f := <-session.send:
+		frames = append(frames, f)
+	case <-timeout.C:
+	case <-session.Done():
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "poll session not found",
+		})
+		return
+	case <-ctx.Done():
+		return
+	}
+	// Only this poll reads the queue, queued events are taken without blocking
+	for len(frames) > 0 && len(frames) < maxPollEvents && len(session.send) > 0 {
+		frames = append(frames, <-session.send)
+	}
+
+	events := make([]json.RawMessage, 0, len(frames))
+	for _, f := range frames {
+		events = append(events, f.data)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"session": session.id,
+		"events":  events,
+	})
+	if c.Writer.Written() {
+		for _, f := range frames {
+			f.sent()
+		}
+	}
+}
+
+// openPollSession subscribes a long polling client to its events, until the session is closed.
+// It fails if the replica is shutting down.
+func (handler *MessageHandler) openPollSession(userId string, device string, resume []string, release func()) (*pollSession, bool) {
+	config, _ := config.Get()
+	session := &pollSession{
+		eventQueue: newEventQueue(config.WSSendQueueSize),
+		id:         uuid.New().String(),
+		userId:     userId,
+		release:    release,
+	}
+	// Closing the session when the replica shuts down, the client opening one on another replica
+	untrack, ok := handler.drainer.Track(session.Close)
+	if !ok {
+		return nil, false
+	}
+	session.untrack = untrack
+	session.idle = time.AfterFunc(config.PollSessionTTL, func() {
+		handler.closePollSession(session)
+	})
+
+	handler.pollsM.Lock()
+	handler.polls[session.id] = session
+	handler.pollsM.Unlock()
+
+	// Forwarding outlives the poll opening the session
+	go func() {
+		handler.forwardMessages(context.Background(), logger.GetLogger(), userId, device, session, resume)
+		handler.closePollSession(session)
+	}()
+	return session, true
+}
+
+// getPollSession returns the open poll session with the ID, only to the user who opened it.
+func (handler *MessageHandler) getPollSession(sessionID string, userId string) *pollSession {
+	handler.pollsM.Lock()
+	defer handler.pollsM.Unlock()
+	session, exists := handler.polls[sessionID]
+	if !exists || session.userId != userId {
+		return nil
+	}
+	return session
+}
+
+// closePollSession forgets the session and stops forwarding its events, it may be called again once closed.
+func (handler *MessageHandler) closePollSession(session *pollSession) {
+	handler.pollsM.Lock()
+	_, open := handler.polls[session.id]
+	delete(handler.polls, session.id)
+	handler.pollsM.Unlock()
+	session.Close()
+	if open {
+		session.untrack()
+		session.release()
+	}
+}
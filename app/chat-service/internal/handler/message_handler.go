@@ -3,13 +3,20 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/config"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/middleware"
 	"github.com/nsmsb/darda-chat/app/chat-service/internal/model"
 	"github.com/nsmsb/darda-chat/app/chat-service/internal/service"
 	"github.com/nsmsb/darda-chat/app/chat-service/internal/utils"
@@ -20,12 +27,28 @@ import (
 type MessageHandler struct {
 	messageService       service.MessageService
 	messageReaderService service.MessageReader
+	presenceService      service.PresenceService
+	deduplicator         service.Deduplicator
+	attachmentService    service.AttachmentService
+	rateLimiter          service.RateLimiter
+	drainer              *Drainer
+	httpTyping           map[string]*httpTypingState // Typing state of the events sent over HTTP, by user
+	httpTypingM          sync.Mutex
+	polls                map[string]*pollSession // Open long polling sessions, by ID
+	pollsM               sync.Mutex
 }
 
-func NewMessageHandler(messageService service.MessageService, messageReaderService service.MessageReader) *MessageHandler {
+func NewMessageHandler(messageService service.MessageService, messageReaderService service.MessageReader, presenceService service.PresenceService, deduplicator service.Deduplicator, attachmentService service.AttachmentService, rateLimiter service.RateLimiter, drainer *Drainer) *MessageHandler {
 	return &MessageHandler{
 		messageService:       messageService,
 		messageReaderService: messageReaderService,
+		presenceService:      presenceService,
+		deduplicator:         deduplicator,
+		attachmentService:    attachmentService,
+		rateLimiter:          rateLimiter,
+		drainer:              drainer,
+		httpTyping:           make(map[string]*httpTypingState),
+		polls:                make(map[string]*pollSession),
 	}
 }
 
@@ -40,18 +63,56 @@ func (handler *MessageHandler) HandleConnections(c *gin.Context) {
 	// prepare logger from context
 	log := logger.GetFromContext(c)
 
-	// Get id of user and handler error
-	userId := c.Query("id")
-	if userId == "" {
-		log.Error("User ID is required")
+	// Get id of the authenticated user
+	userId := c.GetString(middleware.UserIDKey)
+	log.Info("User connected", zap.String("user_id", userId))
+
+	// Cursors of the last message seen in each conversation, to replay those missed while disconnected
+	resume := c.QueryArray("resume")
+	if err := parseResumeCursors(resume); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "user ID is required",
+			"error": err.Error(),
+		})
+		return
+	}
+	// Device the client connects from, to receive the events it missed since it last connected from it
+	device := c.Query("device")
+	if err := parseDevice(device); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Refusing new connections while shutting down, clients connect to another replica
+	if handler.drainer.Draining() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "shutting down",
 		})
 		return
 	}
-	log.Info("User connected", zap.String("user_id", userId))
 
-	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	// Limiting connection attempts and concurrent connections
+	release, ok := handler.acquireConnection(c, userId)
+	if !ok {
+		return
+	}
+	defer release()
+
+	// Negotiating the encoding of events, JSON being used if the client asks for no subprotocol
+	subprotocol, codec, ok := negotiateCodec(c.Request)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "unsupported subprotocol",
+		})
+		return
+	}
+	var header http.Header
+	if subprotocol != "" {
+		header = http.Header{"Sec-Websocket-Protocol": {subprotocol}}
+	}
+
+	ws, err := upgrader.Upgrade(c.Writer, c.Request, header)
 	if err != nil {
 		log.Error("Failed to upgrade to WebSocket", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -59,15 +120,40 @@ func (handler *MessageHandler) HandleConnections(c *gin.Context) {
 		})
 		return
 	}
+	// Frames larger than the limit close the connection with 1009 (message too big) instead of being buffered
+	config, _ := config.Get()
+	ws.SetReadLimit(config.WSMaxMessageSize)
+
+	// Writes to the socket, forwarded messages as acknowledgements, go through its write pump
+	sock := newSocket(ws, codec, config.WSWriteWait, config.WSPongWait, config.WSPingPeriod, config.WSSendQueueSize)
 
-	// Ensure cleanup when the function exits
+	// Ensure cleanup when the function exits, with the close code set by the read loop
+	closeCode, closeText := websocket.CloseNormalClosure, ""
 	defer func() {
-		ws.Close()
+		sock.Close(closeCode, closeText)
 		log.Info("Client disconnected", zap.String("user_id", userId))
 	}()
 
+	// Asking the client to reconnect elsewhere when the replica shuts down
+	untrack, ok := handler.drainer.Track(func() {
+		sock.shutdown(websocket.CloseServiceRestart, "reconnect elsewhere")
+	})
+	if !ok {
+		closeCode, closeText = websocket.CloseServiceRestart, "reconnect elsewhere"
+		return
+	}
+	defer untrack()
+
 	// Reading received messages
-	go handler.forwardMessages(c, userId, ws)
+	go handler.forwardMessages(c.Request.Context(), log, userId, device, sock, resume)
+
+	// Tracking typing state of the connection, and clearing it when the client leaves
+	typing := newTypingTracker(config.TypingTimeout)
+	defer func() {
+		for _, conversationID := range typing.StopAll() {
+			handler.sendTypingEvent(context.Background(), userId, conversationID, model.MessageEventTypingStopped)
+		}
+	}()
 
 	// Sending Messages
 	log.Info("Ready to send messages", zap.String("user_id", userId))
@@ -75,37 +161,71 @@ func (handler *MessageHandler) HandleConnections(c *gin.Context) {
 	for {
 		_, raw, err := ws.ReadMessage()
 		if err != nil {
-			// Check if closed error is because user is disconnected
+			// Check if closed error is because user is disconnected, or didn't answer pings in time
+			var netErr net.Error
 			if websocket.IsCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
 				log.Info("Connection closed by client", zap.String("user_id", userId))
+			} else if errors.Is(err, websocket.ErrReadLimit) {
+				log.Info("Message too big, closing connection", zap.String("user_id", userId))
+				closeCode, closeText = websocket.CloseMessageTooBig, "message too big"
+			} else if errors.As(err, &netErr) && netErr.Timeout() {
+				log.Info("Connection timed out", zap.String("user_id", userId))
+				closeCode, closeText = websocket.CloseGoingAway, "keepalive timeout"
+			} else if sock.Closing() {
+				log.Info("Connection closed by server", zap.String("user_id", userId))
 			} else {
 				log.Error("Error reading from WebSocket", zap.String("user_id", userId), zap.Error(err))
 			}
 			break
 		}
+		sock.Touch()
 
-		// Unmarshal incoming event
-		var event model.Event
-		if err := json.Unmarshal(raw, &event); err != nil {
-			log.Error("Failed to unmarshal event", zap.String("user_id", userId), zap.Error(err))
+		// Decode incoming event
+		event, err := codec.Decode(raw)
+		if err != nil {
+			log.Error("Failed to decode event", zap.String("user_id", userId), zap.Error(err))
+			handler.writeError(log, sock, "", invalidEvent("malformed event"))
 			continue
 		}
 
-		// Process the message event
-		if err := handler.processMessageEvent(c, event, userId); err != nil {
+		// The request ID only correlates the reply, it is never forwarded
+		requestID := event.RequestID
+		event.RequestID = ""
+
+		// Process the message event, then acknowledge it or report why it failed
+		ack, err := handler.processMessageEvent(c, event, userId, typing)
+		if err != nil {
 			log.Error("Failed to process message event", zap.String("user_id", userId), zap.Error(err))
-			// TODO: send error back to client?
+			handler.writeError(log, sock, requestID, err)
+			// Disconnecting clients who keep sending events over their limits
+			if isRateLimited(err) && handler.abusive(c.Request.Context(), userId) {
+				log.Info("Disconnecting client over rate limits", zap.String("user_id", userId))
+				closeCode, closeText = websocket.ClosePolicyViolation, "rate limit exceeded"
+				break
+			}
 			continue
 		}
+		if requestID != "" {
+			ack.RequestID = requestID
+			if err := writeEvent(sock, model.EventTypeAck, ack); err != nil {
+				log.Error("Failed to write ack", zap.String("user_id", userId), zap.Error(err))
+			}
+		}
 	}
 }
 
-// forwardMessages listens for incoming messages for a user and forwards them to the WebSocket.
-func (handler *MessageHandler) forwardMessages(c *gin.Context, userId string, ws *websocket.Conn) {
-	log := logger.GetFromContext(c)
-	ctx := c.Request.Context()
+// writeError reports to the client that its event was refused or failed.
+func (handler *MessageHandler) writeError(log *zap.Logger, w eventWriter, requestID string, err error) {
+	if err := writeEvent(w, model.EventTypeError, toErrorFrame(requestID, err)); err != nil {
+		log.Error("Failed to write error", zap.Error(err))
+	}
+}
 
-	incomingMessages, err := handler.messageService.SubscribeToMessages(ctx, userId)
+// forwardMessages listens for incoming messages for a user and forwards them to the client, until it is disconnected.
+// Messages missed since the resume cursors are replayed first, live messages being held until the replay ends.
+func (handler *MessageHandler) forwardMessages(ctx context.Context, log *zap.Logger, userId string, device string, w eventWriter, resume []string) {
+
+	incomingMessages, err := handler.messageService.SubscribeToMessages(ctx, userId, device)
 	log.Info("Subscribing to messages", zap.String("user_id", userId))
 	if err != nil {
 		log.Error("Failed to subscribe to messages", zap.Error(err))
@@ -123,17 +243,56 @@ func (handler *MessageHandler) forwardMessages(c *gin.Context, userId string, ws
 		}
 	}()
 
+	// Replaying from the reader once subscribed, so messages written meanwhile are either replayed or received live
+	var replayed chan map[string]struct{}
+	var pending []string
+	if len(resume) > 0 {
+		replayed = make(chan map[string]struct{}, 1)
+		go func() {
+			replayed <- handler.replayMessages(ctx, log, userId, resume, w)
+		}()
+	}
+
 	// Listening for incoming messages and forwarding to WebSocket
 	log.Info("Messages listener started", zap.String("user_id", userId))
 	for {
 		select {
 		case msg, ok := <-incomingMessages:
 			if !ok {
-				log.Info("Incoming messages channel closed", zap.String("user_id", userId))
+				// The client would stay connected without receiving events, it reconnects to subscribe again
+				log.Info("Incoming messages channel closed, disconnecting client", zap.String("user_id", userId))
+				w.Disconnect()
+				return
+			}
+			// Holding live messages until the replay ends
+			if replayed != nil {
+				pending = append(pending, msg)
+				continue
+			}
+			// Forwarding message to the client, acknowledging it once written
+			if err := w.WriteFrame(handler.acknowledged(userId, incomingMessages, msg)); err != nil {
+				log.Info("Stopping message listener", zap.String("user_id", userId), zap.Error(err))
 				return
 			}
-			// Forwarding message to WebSocket
-			ws.WriteMessage(websocket.TextMessage, []byte(msg))
+
+		case ids := <-replayed:
+			// Forwarding held messages, but those already replayed, at the pace of the client
+			replayed = nil
+			for _, msg := range pending {
+				if isReplayed(msg, ids) {
+					handler.messageService.Acknowledge(userId, incomingMessages, msg)
+					continue
+				}
+				if err := w.SendFrame(ctx, handler.acknowledged(userId, incomingMessages, msg)); err != nil {
+					log.Info("Stopping message listener", zap.String("user_id", userId), zap.Error(err))
+					return
+				}
+			}
+			pending = nil
+
+		case <-w.Done():
+			log.Info("Client disconnected, stopping message listener", zap.String("user_id", userId))
+			return
 
 		case <-ctx.Done():
 			log.Info("Context done, stopping message listener", zap.String("user_id", userId))
@@ -142,64 +301,624 @@ func (handler *MessageHandler) forwardMessages(c *gin.Context, userId string, ws
 	}
 }
 
-// processMessageEvent processes a message event received from the WebSocket.
-func (handler *MessageHandler) processMessageEvent(c *gin.Context, event model.Event, userId string) error {
+// acknowledged returns the frame of msg, acknowledging it to the message service once written to the client.
+func (handler *MessageHandler) acknowledged(userId string, msgCh <-chan string, msg string) frame {
+	return frame{
+		data: []byte(msg),
+		written: func() {
+			handler.messageService.Acknowledge(userId, msgCh, msg)
+		},
+	}
+}
+
+// processMessageEvent processes a message event received from the WebSocket, and returns the acknowledgement of the accepted event.
+func (handler *MessageHandler) processMessageEvent(c *gin.Context, event model.Event, userId string, typing *typingTracker) (model.Ack, error) {
 	// Prepare logger from context
 	log := logger.GetFromContext(c)
 
-	// Checking event type
-	if event.Type != model.EventTypeMessage && event.Type != model.EventTypeMessageEvent {
-		log.Error("Unsupported event type", zap.String("user_id", userId), zap.String("event_type", string(event.Type)))
-		return fmt.Errorf("unsupported event type: %s", event.Type)
-	}
-
 	// Creating Id and Timestamp for MessageEvent
 	event.Timestamp = time.Now().UTC()
 	event.EventID = uuid.New().String()
-	// Handling Message event
-	if event.Type == model.EventTypeMessage {
-		// Unmarshal message content
-		var msg model.Message
-		if err := json.Unmarshal(event.Content, &msg); err != nil {
-			log.Error("Failed to unmarshal message", zap.String("user_id", userId), zap.Error(err))
-			return fmt.Errorf("failed to unmarshal message: %w", err)
-		}
-		// Validation of Message
-		if msg.Destination == "" || msg.Content == "" {
-			log.Error("Invalid message: missing destination or content", zap.String("user_id", userId))
-			return fmt.Errorf("invalid message: missing destination or content")
-		}
-
-		// Adding current time in UTC to avoid server-local timezone differences
-		msg.Timestamp = event.Timestamp
-		// Setting ID if not provided by the client
-		if msg.ID == "" {
-			msg.ID = event.EventID
+
+	// Limiting how often the user sends events of each type
+	if err := handler.limitEvent(c.Request.Context(), userId, event.Type); err != nil {
+		return model.Ack{}, err
+	}
+
+	// Handling event depending on its type
+	switch event.Type {
+	case model.EventTypeMessage:
+		return handler.processMessage(c, event, userId)
+	case model.EventTypeMessageEvent:
+		return handler.processMessageEventContent(c, event, userId, typing)
+	case model.EventTypeMessageEdit:
+		return handler.processEdit(c, event, userId)
+	case model.EventTypeMessageDelete:
+		return handler.processDelete(c, event, userId)
+	default:
+		log.Error("Unsupported event type", zap.String("user_id", userId), zap.String("event_type", string(event.Type)))
+		return model.Ack{}, &eventError{code: model.ErrorCodeUnsupportedEvent, message: fmt.Sprintf("unsupported event type: %s", event.Type)}
+	}
+}
+
+// processMessage validates a chat message, then persists it and delivers it to its recipients.
+func (handler *MessageHandler) processMessage(c *gin.Context, event model.Event, userId string) (model.Ack, error) {
+	// Prepare logger from context
+	log := logger.GetFromContext(c)
+
+	// Unmarshal message content
+	var msg model.Message
+	if err := json.Unmarshal(event.Content, &msg); err != nil {
+		log.Error("Failed to unmarshal message", zap.String("user_id", userId), zap.Error(err))
+		return model.Ack{}, invalidEvent("malformed message")
+	}
+	// The sender is always the connected user, messages on behalf of someone else are refused
+	if msg.Sender != "" && msg.Sender != userId {
+		log.Warn("Refused message with forged sender", zap.String("user_id", userId), zap.String("sender", msg.Sender))
+		return model.Ack{}, invalidEvent("invalid message: sender %s does not match connected user", msg.Sender)
+	}
+	msg.Sender = userId
+	msg.EditedAt = time.Time{}
+	msg.DeletedAt = time.Time{}
+	msg.DeletedBy = ""
+	msg.Reactions = nil
+	msg.ReplyCount = 0
+	msg.LastReplyAt = time.Time{}
+
+	// Validation of Message, group messages are addressed by conversation ID instead of destination
+	isGroupMessage := utils.IsGroupConvId(msg.ConversationID)
+	if (msg.Destination == "" && !isGroupMessage) || (msg.Content == "" && len(msg.Attachments) == 0) {
+		log.Error("Invalid message: missing destination or content", zap.String("user_id", userId))
+		return model.Ack{}, invalidEvent("invalid message: missing destination or content")
+	}
+	if len(msg.Attachments) > maxMessageAttachments {
+		return model.Ack{}, invalidEvent("invalid message: more than %d attachments", maxMessageAttachments)
+	}
+
+	// Adding current time in UTC to avoid server-local timezone differences
+	msg.Timestamp = event.Timestamp
+	// Setting ID if not provided by the client, client IDs make retried sends idempotent
+	clientID := msg.ID != ""
+	if !clientID {
+		msg.ID = event.EventID
+	}
+
+	// Resolving the recipients of the message
+	var destinations []string
+	if isGroupMessage {
+		recipients, err := handler.groupRecipients(c.Request.Context(), msg.ConversationID, msg.Sender)
+		if err != nil {
+			log.Error("Failed to resolve group recipients", zap.String("user_id", userId), zap.String("conversation", msg.ConversationID), zap.Error(err))
+			return model.Ack{}, err
 		}
+		msg.Destination = ""
+		destinations = recipients
+	} else {
 		// Generate conversation ID based on sender and destination
 		msg.ConversationID = utils.GenerateConvId(msg.Sender, msg.Destination)
+		destinations = []string{msg.Destination}
+	}
 
-		// Sending Message to Destination
-		if strMsg, err := json.Marshal(msg); err == nil {
-			event.Content = json.RawMessage(strMsg)
-			strEvent, err := json.Marshal(event)
-			if err != nil {
-				log.Error("Failed to marshal event", zap.String("user_id", userId), zap.Error(err))
+	// Mentioned users must be recipients of the message, each mentioned once
+	slices.Sort(msg.Mentions)
+	msg.Mentions = slices.Compact(msg.Mentions)
+	for _, mention := range msg.Mentions {
+		if !slices.Contains(destinations, mention) {
+			return model.Ack{}, invalidEvent("invalid message: mentioned user %s is not a recipient", mention)
+		}
+	}
+
+	// Recipients who blocked the sender don't get the message, direct messages to them are refused
+	destinations, err := handler.unblockedRecipients(c.Request.Context(), msg.Sender, destinations)
+	if err != nil {
+		log.Error("Failed to check blocks", zap.String("user_id", userId), zap.String("conversation", msg.ConversationID), zap.Error(err))
+		return model.Ack{}, err
+	}
+	if !isGroupMessage && len(destinations) == 0 {
+		// The sender is not told about the block
+		log.Info("Message refused by its recipient", zap.String("user_id", userId), zap.String("conversation", msg.ConversationID))
+		return model.Ack{}, &eventError{code: model.ErrorCodeDeliveryFailed, message: "message could not be delivered"}
+	}
+
+	// Quoted messages and thread roots must be part of the conversation
+	if err := handler.resolveReplies(c.Request.Context(), &msg); err != nil {
+		log.Error("Failed to resolve replied messages", zap.String("user_id", userId), zap.String("conversation", msg.ConversationID), zap.Error(err))
+		return model.Ack{}, err
+	}
+	// Attachments must be uploaded by the sender to the conversation, their metadata is taken from the upload
+	if err := handler.resolveAttachments(c.Request.Context(), &msg); err != nil {
+		log.Error("Failed to resolve attachments", zap.String("user_id", userId), zap.String("conversation", msg.ConversationID), zap.Error(err))
+		return model.Ack{}, err
+	}
+
+	ack := model.Ack{
+		MessageID:      msg.ID,
+		ConversationID: msg.ConversationID,
+		Timestamp:      msg.Timestamp,
+	}
+
+	// A retried send is acknowledged as the original one without being sent again
+	if clientID {
+		storedAck, duplicate, err := handler.deduplicator.Reserve(c.Request.Context(), userId, msg.ID, ack)
+		if err != nil {
+			// The writer and reader also drop duplicates, only the recipients' sockets may see them twice
+			log.Error("Failed to deduplicate message", zap.String("user_id", userId), zap.Error(err))
+		}
+		if duplicate {
+			log.Info("Duplicate message ignored", zap.String("user_id", userId), zap.String("message_id", msg.ID))
+			return storedAck, nil
+		}
+	}
+
+	// Sending Message to Destination
+	if strMsg, err := json.Marshal(msg); err == nil {
+		event.Content = json.RawMessage(strMsg)
+		strEvent, err := json.Marshal(event)
+		if err != nil {
+			log.Error("Failed to marshal event", zap.String("user_id", userId), zap.Error(err))
+			return model.Ack{}, fmt.Errorf("failed to marshal event: %w", err)
+		}
+		// Sending message via message service
+		err = handler.messageService.SendMessage(c.Request.Context(), destinations, string(strEvent))
+		if err != nil {
+			log.Error("Failed to send message", zap.String("user_id", userId), zap.Error(err))
+			// Letting the client retry with the same ID
+			if clientID {
+				if err := handler.deduplicator.Release(c.Request.Context(), userId, msg.ID); err != nil {
+					log.Error("Failed to release message ID", zap.String("user_id", userId), zap.Error(err))
+				}
 			}
-			// Sending message via message service
-			err = handler.messageService.SendMessage(c.Request.Context(), msg.Destination, string(strEvent))
+			return model.Ack{}, fmt.Errorf("failed to send message: %w", err)
+		}
+		// Recipients become contacts of the sender to be notified of each other's presence
+		if err := handler.presenceService.AddContacts(c.Request.Context(), msg.Sender, destinations); err != nil {
+			log.Error("Failed to add contacts", zap.String("user_id", userId), zap.Error(err))
+		}
+	} else {
+		log.Error("Failed to marshal message", zap.String("user_id", userId), zap.Error(err))
+		return model.Ack{}, fmt.Errorf("failed to marshal message: %w", err)
+	}
+	return ack, nil
+}
+
+// resolveReplies checks the messages a message quotes or replies in the thread of belong to its conversation.
+// Replies to a reply in a thread are attached to the root of that thread.
+func (handler *MessageHandler) resolveReplies(ctx context.Context, msg *model.Message) error {
+	if msg.ReplyTo != "" {
+		quoted, err := handler.messageReaderService.GetMessage(ctx, msg.ReplyTo)
+		if err != nil {
+			return fmt.Errorf("failed to get quoted message: %w", err)
+		}
+		if quoted.ConversationID != msg.ConversationID {
+			return invalidEvent("invalid message: quoted message %s is not part of the conversation", msg.ReplyTo)
+		}
+	}
+	if msg.ThreadRootID != "" {
+		root, err := handler.messageReaderService.GetMessage(ctx, msg.ThreadRootID)
+		if err != nil {
+			return fmt.Errorf("failed to get thread root: %w", err)
+		}
+		if root.ConversationID != msg.ConversationID {
+			return invalidEvent("invalid message: thread root %s is not part of the conversation", msg.ThreadRootID)
+		}
+		if root.ThreadRootID != "" {
+			msg.ThreadRootID = root.ThreadRootID
+		}
+	}
+	return nil
+}
+
+// processEdit validates an edit of a message by its sender, then persists it and relays it to the other participants.
+func (handler *MessageHandler) processEdit(c *gin.Context, event model.Event, userId string) (model.Ack, error) {
+	// Prepare logger from context
+	log := logger.GetFromContext(c)
+	ctx := c.Request.Context()
+
+	var edit model.MessageEdit
+	if err := json.Unmarshal(event.Content, &edit); err != nil {
+		log.Error("Failed to unmarshal message edit", zap.String("user_id", userId), zap.Error(err))
+		return model.Ack{}, invalidEvent("malformed message edit")
+	}
+	if edit.MessageID == "" || edit.Content == "" {
+		return model.Ack{}, invalidEvent("invalid message edit: missing message or content")
+	}
+
+	// Only the sender can edit the message, which must be written already
+	msg, err := handler.messageReaderService.GetMessage(ctx, edit.MessageID)
+	if err != nil {
+		log.Error("Failed to get edited message", zap.String("user_id", userId), zap.String("message_id", edit.MessageID), zap.Error(err))
+		return model.Ack{}, err
+	}
+	if msg.Sender != userId {
+		return model.Ack{}, &eventError{code: model.ErrorCodeForbidden, message: "only the sender can edit the message"}
+	}
+	recipients, err := handler.groupRecipients(ctx, msg.ConversationID, userId)
+	if err != nil {
+		log.Error("Failed to resolve edit recipients", zap.String("user_id", userId), zap.String("conversation", msg.ConversationID), zap.Error(err))
+		return model.Ack{}, err
+	}
+
+	edit.ConversationID = msg.ConversationID
+	edit.Sender = userId
+	edit.EditedAt = event.Timestamp
+
+	strEdit, err := json.Marshal(edit)
+	if err != nil {
+		return model.Ack{}, fmt.Errorf("failed to marshal message edit: %w", err)
+	}
+	event.Content = json.RawMessage(strEdit)
+	strEvent, err := json.Marshal(event)
+	if err != nil {
+		return model.Ack{}, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	// Persisting the edit and relaying it to the other participants' sockets
+	if err := handler.messageService.SendMessage(ctx, recipients, string(strEvent)); err != nil {
+		log.Error("Failed to send message edit", zap.String("user_id", userId), zap.Error(err))
+		return model.Ack{}, fmt.Errorf("failed to send message edit: %w", err)
+	}
+	return model.Ack{
+		MessageID:      edit.MessageID,
+		ConversationID: edit.ConversationID,
+		Timestamp:      edit.EditedAt,
+	}, nil
+}
+
+// processDelete validates the deletion of a message, then persists it and relays it.
+// A deletion for everyone, by the sender or a group admin, is relayed to the other participants,
+// and a deletion for the user only to the user's other sockets.
+func (handler *MessageHandler) processDelete(c *gin.Context, event model.Event, userId string) (model.Ack, error) {
+	// Prepare logger from context
+	log := logger.GetFromContext(c)
+	ctx := c.Request.Context()
+
+	var del model.MessageDelete
+	if err := json.Unmarshal(event.Content, &del); err != nil {
+		log.Error("Failed to unmarshal message delete", zap.String("user_id", userId), zap.Error(err))
+		return model.Ack{}, invalidEvent("malformed message delete")
+	}
+	if del.MessageID == "" || (del.Scope != model.DeleteForEveryone && del.Scope != model.DeleteForMe) {
+		return model.Ack{}, invalidEvent("invalid message delete: missing message or unsupported scope")
+	}
+
+	msg, err := handler.messageReaderService.GetMessage(ctx, del.MessageID)
+	if err != nil {
+		log.Error("Failed to get deleted message", zap.String("user_id", userId), zap.String("message_id", del.MessageID), zap.Error(err))
+		return model.Ack{}, err
+	}
+	recipients, err := handler.groupRecipients(ctx, msg.ConversationID, userId)
+	if err != nil {
+		log.Error("Failed to resolve delete recipients", zap.String("user_id", userId), zap.String("conversation", msg.ConversationID), zap.Error(err))
+		return model.Ack{}, err
+	}
+
+	if del.Scope == model.DeleteForEveryone {
+		// Only the sender or an admin of the group can delete a message for everyone
+		if msg.Sender != userId {
+			admin, err := handler.isGroupAdmin(ctx, msg.ConversationID, userId)
 			if err != nil {
-				log.Error("Failed to send message", zap.String("user_id", userId), zap.Error(err))
-				return fmt.Errorf("failed to send message: %w", err)
+				return model.Ack{}, err
+			}
+			if !admin {
+				return model.Ack{}, &eventError{code: model.ErrorCodeForbidden, message: "only the sender or a group admin can delete the message for everyone"}
 			}
-		} else {
-			log.Error("Failed to marshal message", zap.String("user_id", userId), zap.Error(err))
-			return fmt.Errorf("failed to marshal message: %w", err)
 		}
+	} else {
+		recipients = []string{userId}
+	}
+
+	del.ConversationID = msg.ConversationID
+	del.UserID = userId
+	del.DeletedAt = event.Timestamp
+
+	strDel, err := json.Marshal(del)
+	if err != nil {
+		return model.Ack{}, fmt.Errorf("failed to marshal message delete: %w", err)
+	}
+	event.Content = json.RawMessage(strDel)
+	strEvent, err := json.Marshal(event)
+	if err != nil {
+		return model.Ack{}, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	// Persisting the deletion and relaying it as a retraction
+	if err := handler.messageService.SendMessage(ctx, recipients, string(strEvent)); err != nil {
+		log.Error("Failed to send message delete", zap.String("user_id", userId), zap.Error(err))
+		return model.Ack{}, fmt.Errorf("failed to send message delete: %w", err)
+	}
+	return model.Ack{
+		MessageID:      del.MessageID,
+		ConversationID: del.ConversationID,
+		Timestamp:      del.DeletedAt,
+	}, nil
+}
+
+// isGroupAdmin reports whether the user administrates the group conversation, the creator of a group being its admin.
+func (handler *MessageHandler) isGroupAdmin(ctx context.Context, conversationID string, userId string) (bool, error) {
+	if !utils.IsGroupConvId(conversationID) {
+		return false, nil
+	}
+	group, err := handler.messageReaderService.GetGroup(ctx, conversationID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get group: %w", err)
+	}
+	return group.CreatedBy == userId, nil
+}
+
+// processMessageEventContent dispatches message events between persisted receipts and ephemeral typing events.
+func (handler *MessageHandler) processMessageEventContent(c *gin.Context, event model.Event, userId string, typing *typingTracker) (model.Ack, error) {
+	// Unmarshal message event content
+	var msgEvent model.MessageEvent
+	if err := json.Unmarshal(event.Content, &msgEvent); err != nil {
+		logger.GetFromContext(c).Error("Failed to unmarshal message event", zap.String("user_id", userId), zap.Error(err))
+		return model.Ack{}, invalidEvent("malformed message event")
+	}
+
+	var err error
+	switch msgEvent.Type {
+	case model.MessageEventDelivered, model.MessageEventRead:
+		err = handler.processReceipt(c, event, msgEvent, userId)
+	case model.MessageEventTypingStarted, model.MessageEventTypingStopped:
+		err = handler.processTyping(c, msgEvent, userId, typing)
+	case model.MessageEventReactionAdded, model.MessageEventReactionRemoved:
+		err = handler.processReaction(c, event, &msgEvent, userId)
+	default:
+		err = &eventError{code: model.ErrorCodeUnsupportedEvent, message: fmt.Sprintf("unsupported message event type: %s", msgEvent.Type)}
+	}
+	if err != nil {
+		return model.Ack{}, err
+	}
+	return model.Ack{
+		ConversationID: msgEvent.ConversationID,
+		Timestamp:      event.Timestamp,
+	}, nil
+}
+
+// processReaction validates a reaction added to or removed from a message, then persists it and relays it to the other participants.
+func (handler *MessageHandler) processReaction(c *gin.Context, event model.Event, msgEvent *model.MessageEvent, userId string) error {
+	// Prepare logger from context
+	log := logger.GetFromContext(c)
+	ctx := c.Request.Context()
+
+	// Reactions are short strings such as emojis, stored as field names
+	if msgEvent.MessageID == "" || msgEvent.Reaction == "" || len(msgEvent.Reaction) > 64 || strings.ContainsAny(msgEvent.Reaction, ".$") {
+		return invalidEvent("invalid reaction: missing message or invalid reaction")
+	}
+
+	msg, err := handler.messageReaderService.GetMessage(ctx, msgEvent.MessageID)
+	if err != nil {
+		log.Error("Failed to get reacted message", zap.String("user_id", userId), zap.String("message_id", msgEvent.MessageID), zap.Error(err))
+		return err
+	}
+	if !msg.DeletedAt.IsZero() {
+		return invalidEvent("invalid reaction: message deleted")
+	}
+	recipients, err := handler.groupRecipients(ctx, msg.ConversationID, userId)
+	if err != nil {
+		log.Error("Failed to resolve reaction recipients", zap.String("user_id", userId), zap.String("conversation", msg.ConversationID), zap.Error(err))
+		return err
+	}
+
+	// The reaction is made by the connected user at the time it is received
+	msgEvent.ConversationID = msg.ConversationID
+	msgEvent.Sender = msg.Sender
+	msgEvent.MessageTimestamp = msg.Timestamp
+	msgEvent.UserID = userId
+	msgEvent.Timestamp = event.Timestamp
+
+	strMsgEvent, err := json.Marshal(msgEvent)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message event: %w", err)
+	}
+	event.Content = json.RawMessage(strMsgEvent)
+	strEvent, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	// Persisting the reaction and relaying it to the other participants' sockets
+	if err := handler.messageService.SendMessage(ctx, recipients, string(strEvent)); err != nil {
+		log.Error("Failed to send reaction", zap.String("user_id", userId), zap.Error(err))
+		return fmt.Errorf("failed to send reaction: %w", err)
+	}
+	return nil
+}
+
+// processTyping relays a typing state change to the other participants of the conversation.
+// A started state expires on its own after the configured timeout unless the client renews it.
+func (handler *MessageHandler) processTyping(c *gin.Context, msgEvent model.MessageEvent, userId string, typing *typingTracker) error {
+	if msgEvent.ConversationID == "" {
+		return invalidEvent("invalid typing event: missing conversation")
+	}
+
+	if msgEvent.Type == model.MessageEventTypingStopped {
+		// Nothing to relay if the state already expired
+		if !typing.Stop(msgEvent.ConversationID) {
+			return nil
+		}
+		return handler.sendTypingEvent(c.Request.Context(), userId, msgEvent.ConversationID, model.MessageEventTypingStopped)
+	}
+
+	if err := handler.sendTypingEvent(c.Request.Context(), userId, msgEvent.ConversationID, model.MessageEventTypingStarted); err != nil {
+		return err
+	}
+	typing.Start(msgEvent.ConversationID, func() {
+		handler.sendTypingEvent(context.Background(), userId, msgEvent.ConversationID, model.MessageEventTypingStopped)
+	})
+	return nil
+}
+
+// sendTypingEvent publishes a typing event of the user to the other participants of the conversation, without persisting it.
+func (handler *MessageHandler) sendTypingEvent(ctx context.Context, userId string, conversationID string, eventType string) error {
+	log := logger.GetLogger()
+
+	recipients, err := handler.groupRecipients(ctx, conversationID, userId)
+	if err != nil {
+		log.Error("Failed to resolve typing recipients", zap.String("user_id", userId), zap.String("conversation", conversationID), zap.Error(err))
+		return err
+	}
+
+	now := time.Now().UTC()
+	content, err := json.Marshal(model.MessageEvent{
+		Type:           eventType,
+		ConversationID: conversationID,
+		UserID:         userId,
+		Timestamp:      now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal message event: %w", err)
+	}
+	strEvent, err := json.Marshal(model.Event{
+		Type:      model.EventTypeMessageEvent,
+		EventID:   uuid.New().String(),
+		Timestamp: now,
+		Content:   content,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	if err := handler.messageService.SendEphemeral(ctx, recipients, string(strEvent)); err != nil {
+		log.Error("Failed to send typing event", zap.String("user_id", userId), zap.Error(err))
+		return fmt.Errorf("failed to send typing event: %w", err)
 	}
 	return nil
 }
 
+// processReceipt validates a delivery or read receipt, then persists it and relays it to the sender of the message.
+func (handler *MessageHandler) processReceipt(c *gin.Context, event model.Event, msgEvent model.MessageEvent, userId string) error {
+	// Prepare logger from context
+	log := logger.GetFromContext(c)
+
+	// Validation of MessageEvent
+	if msgEvent.MessageID == "" {
+		log.Error("Invalid message event: missing message", zap.String("user_id", userId))
+		return invalidEvent("invalid message event: missing message")
+	}
+
+	// The receipt positions the user on the stored message, never on values claimed by the client
+	msg, err := handler.messageReaderService.GetMessage(c.Request.Context(), msgEvent.MessageID)
+	if err != nil {
+		log.Error("Failed to get received message", zap.String("user_id", userId), zap.String("message_id", msgEvent.MessageID), zap.Error(err))
+		return err
+	}
+	if msgEvent.ConversationID != "" && msgEvent.ConversationID != msg.ConversationID {
+		return invalidEvent("invalid message event: message not in conversation")
+	}
+
+	// The reporting user must take part in the conversation of the message
+	members, err := handler.conversationMembers(c.Request.Context(), msg.ConversationID)
+	if err != nil {
+		log.Error("Failed to resolve conversation members", zap.String("user_id", userId), zap.String("conversation", msg.ConversationID), zap.Error(err))
+		return err
+	}
+	if !slices.Contains(members, userId) {
+		return fmt.Errorf("user %s of conversation %s: %w", userId, msg.ConversationID, errNotParticipant)
+	}
+
+	// The receipt is reported by the connected user at the time it is received
+	msgEvent.ConversationID = msg.ConversationID
+	msgEvent.Sender = msg.Sender
+	msgEvent.MessageTimestamp = msg.Timestamp
+	msgEvent.UserID = userId
+	msgEvent.Timestamp = event.Timestamp
+
+	strMsgEvent, err := json.Marshal(msgEvent)
+	if err != nil {
+		log.Error("Failed to marshal message event", zap.String("user_id", userId), zap.Error(err))
+		return fmt.Errorf("failed to marshal message event: %w", err)
+	}
+	event.Content = json.RawMessage(strMsgEvent)
+	strEvent, err := json.Marshal(event)
+	if err != nil {
+		log.Error("Failed to marshal event", zap.String("user_id", userId), zap.Error(err))
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	// Persisting the receipt and relaying it to the sender's sockets
+	if err := handler.messageService.SendMessage(c.Request.Context(), []string{msgEvent.Sender}, string(strEvent)); err != nil {
+		log.Error("Failed to send message event", zap.String("user_id", userId), zap.Error(err))
+		return fmt.Errorf("failed to send message event: %w", err)
+	}
+	return nil
+}
+
+// conversationMembers returns the participants of a direct or group conversation.
+func (handler *MessageHandler) conversationMembers(ctx context.Context, conversationID string) ([]string, error) {
+	if !utils.IsGroupConvId(conversationID) {
+		return utils.ParseConvId(conversationID), nil
+	}
+	group, err := handler.messageReaderService.GetGroup(ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+	return group.Members, nil
+}
+
+// groupRecipients returns the members of a conversation other than the sender, the sender must be a member of it.
+func (handler *MessageHandler) groupRecipients(ctx context.Context, conversationID string, sender string) ([]string, error) {
+	members, err := handler.conversationMembers(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(members, sender) {
+		return nil, fmt.Errorf("user %s of conversation %s: %w", sender, conversationID, errNotParticipant)
+	}
+
+	recipients := make([]string, 0, len(members))
+	for _, member := range members {
+		if member != sender {
+			recipients = append(recipients, member)
+		}
+	}
+	return recipients, nil
+}
+
+// unblockedRecipients returns the recipients of a sender without those who blocked them.
+func (handler *MessageHandler) unblockedRecipients(ctx context.Context, sender string, recipients []string) ([]string, error) {
+	if len(recipients) == 0 {
+		return recipients, nil
+	}
+	blockers, err := handler.messageReaderService.ListBlockers(ctx, sender, recipients)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blockers: %w", err)
+	}
+	return slices.DeleteFunc(recipients, func(recipient string) bool { return slices.Contains(blockers, recipient) }), nil
+}
+
+// resolveConversation returns the conversation ID between the user and a destination user or group.
+// Reading a group requires membership, on failure the error response is written and false is returned.
+func resolveConversation(c *gin.Context, ctx context.Context, messageReaderService service.MessageReader, userId string, destination string) (string, bool) {
+	// Prepare logger from context
+	log := logger.GetFromContext(c)
+
+	if !utils.IsGroupConvId(destination) {
+		return utils.GenerateConvId(userId, destination), true
+	}
+
+	group, err := messageReaderService.GetGroup(ctx, destination)
+	if errors.Is(err, service.ErrGroupNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "group not found",
+		})
+		return "", false
+	}
+	if err != nil {
+		log.Error("Failed to get group", zap.String("conversation", destination), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get group",
+		})
+		return "", false
+	}
+	if !slices.Contains(group.Members, userId) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "not a member of the group",
+		})
+		return "", false
+	}
+	return destination, true
+}
+
 // GetMessages handles HTTP requests to retrieve messages for a conversation.
 func (handler *MessageHandler) GetMessages(c *gin.Context) {
 	// Prepare logger from context
@@ -209,17 +928,20 @@ func (handler *MessageHandler) GetMessages(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	// Get sender from query, user from path and generate conversation ID
-	sender := c.Query("id")
+	// Get sender from the token, user (or group conversation ID) from path and resolve conversation ID
+	sender := c.GetString(middleware.UserIDKey)
 	destination := c.Param("user")
-	conversationID := utils.GenerateConvId(sender, destination)
+	conversationID, ok := resolveConversation(c, ctx, handler.messageReaderService, sender, destination)
+	if !ok {
+		return
+	}
 
 	// Getting cursor parameters from request query
 	before := c.Query("before")
 	after := c.Query("after")
 
-	// Fetch messages using MessageReaderService
-	messages, err := handler.messageReaderService.GetMessages(ctx, conversationID, before, after)
+	// Fetch messages using MessageReaderService, cursors are computed by the reader as it leaves out messages the user deleted
+	page, err := handler.messageReaderService.GetMessages(ctx, conversationID, sender, before, after)
 	if err != nil {
 		log.Error("Failed to get messages", zap.String("conversation", conversationID), zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -228,19 +950,97 @@ func (handler *MessageHandler) GetMessages(c *gin.Context) {
 		return
 	}
 
-	// Determine older and newer cursors
-	var olderCursor, newerCursor string
-	if len(messages) > 0 {
-		last := messages[len(messages)-1]
-		first := messages[0]
-		newerCursor = fmt.Sprintf("%s_%s", last.Timestamp.Format(time.RFC3339Nano), last.ID)
-		olderCursor = fmt.Sprintf("%s_%s", first.Timestamp.Format(time.RFC3339Nano), first.ID)
+	// Return messages as JSON response
+	c.JSON(http.StatusOK, gin.H{
+		"messages": page.Messages,
+		"before":   page.Before,
+		"after":    page.After,
+	})
+}
+
+// GetThread handles HTTP requests to retrieve a root message and the replies in its thread.
+func (handler *MessageHandler) GetThread(c *gin.Context) {
+	// Prepare logger from context
+	log := logger.GetFromContext(c)
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	// Get user from the token and root message from path
+	userId := c.GetString(middleware.UserIDKey)
+	threadRootID := c.Param("message")
+
+	// Getting cursor parameters from request query
+	before := c.Query("before")
+	after := c.Query("after")
+
+	page, err := handler.messageReaderService.GetThread(ctx, threadRootID, userId, before, after)
+	if errors.Is(err, service.ErrMessageNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "message not found",
+		})
+		return
+	}
+	if err != nil {
+		log.Error("Failed to get thread", zap.String("message_id", threadRootID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get thread",
+		})
+		return
+	}
+
+	// Only participants of the conversation of the root message can read its thread
+	members, err := handler.conversationMembers(ctx, page.Root.ConversationID)
+	if err != nil {
+		log.Error("Failed to get thread participants", zap.String("conversation", page.Root.ConversationID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get thread",
+		})
+		return
+	}
+	if !slices.Contains(members, userId) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "not a participant of the conversation",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"root":     page.Root,
+		"messages": page.Messages,
+		"before":   page.Before,
+		"after":    page.After,
+	})
+}
+
+// GetReceipts handles HTTP requests to retrieve the latest delivery and read positions of each participant of a conversation.
+func (handler *MessageHandler) GetReceipts(c *gin.Context) {
+	// Prepare logger from context
+	log := logger.GetFromContext(c)
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	// Get sender from the token, user (or group conversation ID) from path and resolve conversation ID
+	sender := c.GetString(middleware.UserIDKey)
+	destination := c.Param("user")
+	conversationID, ok := resolveConversation(c, ctx, handler.messageReaderService, sender, destination)
+	if !ok {
+		return
+	}
+
+	receipts, err := handler.messageReaderService.GetReceipts(ctx, conversationID)
+	if err != nil {
+		log.Error("Failed to get receipts", zap.String("conversation", conversationID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get receipts",
+		})
+		return
 	}
 
-	// Return messages as JSON response
 	c.JSON(http.StatusOK, gin.H{
-		"messages": messages,
-		"before":   olderCursor,
-		"after":    newerCursor,
+		"receipts": receipts,
 	})
 }
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/service"
+	"github.com/nsmsb/darda-chat/app/chat-service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type PresenceHandler struct {
+	presenceService service.PresenceService
+}
+
+func NewPresenceHandler(presenceService service.PresenceService) *PresenceHandler {
+	return &PresenceHandler{
+		presenceService: presenceService,
+	}
+}
+
+// GetPresence handles HTTP requests to retrieve the online status and last seen time of a user.
+func (handler *PresenceHandler) GetPresence(c *gin.Context) {
+	// Prepare logger from context
+	log := logger.GetFromContext(c)
+
+	userId := c.Param("user")
+	presence, err := handler.presenceService.GetPresence(c.Request.Context(), userId)
+	if err != nil {
+		log.Error("Failed to get presence", zap.String("user_id", userId), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get presence",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, presence)
+}
@@ -0,0 +1,316 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+	pb "github.com/nsmsb/darda-chat/app/chat-service/internal/api/event/gen"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/model"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// protoCodec exchanges events as protobuf in binary frames, the content of JSON events being converted to their schema.
+type protoCodec struct{}
+
+func (protoCodec) Decode(data []byte) (model.Event, error) {
+	var frame pb.Event
+	if err := proto.Unmarshal(data, &frame); err != nil {
+		return model.Event{}, err
+	}
+	event := model.Event{
+		EventID:   frame.GetEventId(),
+		RequestID: frame.GetRequestId(),
+		Timestamp: fromTimestamp(frame.GetTimestamp()),
+	}
+
+	// Only events clients send carry their content, others are refused as unsupported by the handler
+	var content any
+	switch c := frame.GetContent().(type) {
+	case *pb.Event_Message:
+		event.Type, content = model.EventTypeMessage, fromProtoMessage(c.Message)
+	case *pb.Event_MessageEvent:
+		event.Type, content = model.EventTypeMessageEvent, fromProtoMessageEvent(c.MessageEvent)
+	case *pb.Event_MessageEdit:
+		event.Type, content = model.EventTypeMessageEdit, fromProtoMessageEdit(c.MessageEdit)
+	case *pb.Event_MessageDelete:
+		event.Type, content = model.EventTypeMessageDelete, fromProtoMessageDelete(c.MessageDelete)
+	case *pb.Event_Group:
+		event.Type = model.EventTypeGroup
+	case *pb.Event_Presence:
+		event.Type = model.EventTypePresence
+	case *pb.Event_UnreadCount:
+		event.Type = model.EventTypeUnreadCount
+	case *pb.Event_Resumed:
+		event.Type = model.EventTypeResumed
+	case *pb.Event_Ack:
+		event.Type = model.EventTypeAck
+	case *pb.Event_Error:
+		event.Type = model.EventTypeError
+	}
+	if content != nil {
+		raw, err := json.Marshal(content)
+		if err != nil {
+			return model.Event{}, fmt.Errorf("failed to marshal event content: %w", err)
+		}
+		event.Content = raw
+	}
+	return event, nil
+}
+
+func (protoCodec) Encode(data []byte) ([]byte, error) {
+	var event model.Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event: %w", err)
+	}
+	frame := &pb.Event{
+		EventId:   event.EventID,
+		RequestId: event.RequestID,
+		Timestamp: toTimestamp(event.Timestamp),
+	}
+
+	var err error
+	switch event.Type {
+	case model.EventTypeMessage:
+		var msg model.Message
+		if err = json.Unmarshal(event.Content, &msg); err == nil {
+			frame.Content = &pb.Event_Message{Message: toProtoMessage(&msg)}
+		}
+	case model.EventTypeMessageEvent:
+		var msgEvent model.MessageEvent
+		if err = json.Unmarshal(event.Content, &msgEvent); err == nil {
+			frame.Content = &pb.Event_MessageEvent{MessageEvent: toProtoMessageEvent(&msgEvent)}
+		}
+	case model.EventTypeMessageEdit:
+		var edit model.MessageEdit
+		if err = json.Unmarshal(event.Content, &edit); err == nil {
+			frame.Content = &pb.Event_MessageEdit{MessageEdit: &pb.MessageEdit{
+				MessageId:      edit.MessageID,
+				ConversationId: edit.ConversationID,
+				Sender:         edit.Sender,
+				Content:        edit.Content,
+				EditedAt:       toTimestamp(edit.EditedAt),
+			}}
+		}
+	case model.EventTypeMessageDelete:
+		var deletion model.MessageDelete
+		if err = json.Unmarshal(event.Content, &deletion); err == nil {
+			frame.Content = &pb.Event_MessageDelete{MessageDelete: &pb.MessageDelete{
+				MessageId:      deletion.MessageID,
+				ConversationId: deletion.ConversationID,
+				Scope:          deletion.Scope,
+				UserId:         deletion.UserID,
+				DeletedAt:      toTimestamp(deletion.DeletedAt),
+			}}
+		}
+	case model.EventTypeGroup:
+		var group model.Group
+		if err = json.Unmarshal(event.Content, &group); err == nil {
+			frame.Content = &pb.Event_Group{Group: &pb.Group{
+				Id:        group.ID,
+				Name:      group.Name,
+				Members:   group.Members,
+				CreatedBy: group.CreatedBy,
+				CreatedAt: toTimestamp(group.CreatedAt),
+			}}
+		}
+	case model.EventTypePresence:
+		var presence model.Presence
+		if err = json.Unmarshal(event.Content, &presence); err == nil {
+			frame.Content = &pb.Event_Presence{Presence: &pb.Presence{
+				UserId:   presence.UserID,
+				Status:   presence.Status,
+				LastSeen: toTimestamp(presence.LastSeen),
+			}}
+		}
+	case model.EventTypeUnreadCount:
+		var unread model.UnreadCount
+		if err = json.Unmarshal(event.Content, &unread); err == nil {
+			frame.Content = &pb.Event_UnreadCount{UnreadCount: &pb.UnreadCount{
+				ConversationId: unread.ConversationID,
+				UnreadCount:    unread.UnreadCount,
+			}}
+		}
+	case model.EventTypeResumed:
+		var resumed model.Resumed
+		if err = json.Unmarshal(event.Content, &resumed); err == nil {
+			conversations := make([]*pb.ResumedConversation, 0, len(resumed.Conversations))
+			for _, conversation := range resumed.Conversations {
+				conversations = append(conversations, &pb.ResumedConversation{
+					ConversationId: conversation.ConversationID,
+					After:          conversation.After,
+					Replayed:       int64(conversation.Replayed),
+					Truncated:      conversation.Truncated,
+				})
+			}
+			frame.Content = &pb.Event_Resumed{Resumed: &pb.Resumed{Conversations: conversations}}
+		}
+	case model.EventTypeAck:
+		var ack model.Ack
+		if err = json.Unmarshal(event.Content, &ack); err == nil {
+			frame.Content = &pb.Event_Ack{Ack: &pb.Ack{
+				RequestId:      ack.RequestID,
+				MessageId:      ack.MessageID,
+				ConversationId: ack.ConversationID,
+				Timestamp:      toTimestamp(ack.Timestamp),
+			}}
+		}
+	case model.EventTypeError:
+		var eventErr model.Error
+		if err = json.Unmarshal(event.Content, &eventErr); err == nil {
+			frame.Content = &pb.Event_Error{Error: &pb.Error{
+				RequestId:    eventErr.RequestID,
+				Code:         eventErr.Code,
+				Message:      eventErr.Message,
+				RetryAfterMs: eventErr.RetryAfter,
+			}}
+		}
+	default:
+		return nil, fmt.Errorf("no schema for event type: %s", event.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s content: %w", event.Type, err)
+	}
+	return proto.Marshal(frame)
+}
+
+func (protoCodec) FrameType() int {
+	return websocket.BinaryMessage
+}
+
+func toProtoMessage(msg *model.Message) *pb.Message {
+	reactions := make([]*pb.Reaction, 0, len(msg.Reactions))
+	for _, reaction := range msg.Reactions {
+		reactions = append(reactions, &pb.Reaction{Reaction: reaction.Reaction, Users: reaction.Users})
+	}
+	attachments := make([]*pb.Attachment, 0, len(msg.Attachments))
+	for _, attachment := range msg.Attachments {
+		attachments = append(attachments, &pb.Attachment{
+			Id:       attachment.ID,
+			Name:     attachment.Name,
+			MimeType: attachment.MimeType,
+			Size:     attachment.Size,
+			Checksum: attachment.Checksum,
+			Width:    int32(attachment.Width),
+			Height:   int32(attachment.Height),
+		})
+	}
+	return &pb.Message{
+		Id:             msg.ID,
+		ConversationId: msg.ConversationID,
+		Sender:         msg.Sender,
+		Destination:    msg.Destination,
+		Content:        msg.Content,
+		Timestamp:      toTimestamp(msg.Timestamp),
+		EditedAt:       toTimestamp(msg.EditedAt),
+		DeletedAt:      toTimestamp(msg.DeletedAt),
+		DeletedBy:      msg.DeletedBy,
+		Reactions:      reactions,
+		ReplyTo:        msg.ReplyTo,
+		ThreadRootId:   msg.ThreadRootID,
+		ReplyCount:     msg.ReplyCount,
+		LastReplyAt:    toTimestamp(msg.LastReplyAt),
+		Attachments:    attachments,
+		Mentions:       msg.Mentions,
+	}
+}
+
+func fromProtoMessage(msg *pb.Message) model.Message {
+	var reactions []model.Reaction
+	for _, reaction := range msg.GetReactions() {
+		reactions = append(reactions, model.Reaction{Reaction: reaction.GetReaction(), Users: reaction.GetUsers()})
+	}
+	var attachments []model.Attachment
+	for _, attachment := range msg.GetAttachments() {
+		attachments = append(attachments, model.Attachment{
+			ID:       attachment.GetId(),
+			Name:     attachment.GetName(),
+			MimeType: attachment.GetMimeType(),
+			Size:     attachment.GetSize(),
+			Checksum: attachment.GetChecksum(),
+			Width:    int(attachment.GetWidth()),
+			Height:   int(attachment.GetHeight()),
+		})
+	}
+	return model.Message{
+		ID:             msg.GetId(),
+		ConversationID: msg.GetConversationId(),
+		Sender:         msg.GetSender(),
+		Destination:    msg.GetDestination(),
+		Content:        msg.GetContent(),
+		Timestamp:      fromTimestamp(msg.GetTimestamp()),
+		EditedAt:       fromTimestamp(msg.GetEditedAt()),
+		DeletedAt:      fromTimestamp(msg.GetDeletedAt()),
+		DeletedBy:      msg.GetDeletedBy(),
+		Reactions:      reactions,
+		ReplyTo:        msg.GetReplyTo(),
+		ThreadRootID:   msg.GetThreadRootId(),
+		ReplyCount:     msg.GetReplyCount(),
+		LastReplyAt:    fromTimestamp(msg.GetLastReplyAt()),
+		Attachments:    attachments,
+		Mentions:       msg.GetMentions(),
+	}
+}
+
+func toProtoMessageEvent(msgEvent *model.MessageEvent) *pb.MessageEvent {
+	return &pb.MessageEvent{
+		Type:             msgEvent.Type,
+		MessageId:        msgEvent.MessageID,
+		MessageTimestamp: toTimestamp(msgEvent.MessageTimestamp),
+		ConversationId:   msgEvent.ConversationID,
+		Sender:           msgEvent.Sender,
+		UserId:           msgEvent.UserID,
+		Reaction:         msgEvent.Reaction,
+		Timestamp:        toTimestamp(msgEvent.Timestamp),
+	}
+}
+
+func fromProtoMessageEvent(msgEvent *pb.MessageEvent) model.MessageEvent {
+	return model.MessageEvent{
+		Type:             msgEvent.GetType(),
+		MessageID:        msgEvent.GetMessageId(),
+		MessageTimestamp: fromTimestamp(msgEvent.GetMessageTimestamp()),
+		ConversationID:   msgEvent.GetConversationId(),
+		Sender:           msgEvent.GetSender(),
+		UserID:           msgEvent.GetUserId(),
+		Reaction:         msgEvent.GetReaction(),
+		Timestamp:        fromTimestamp(msgEvent.GetTimestamp()),
+	}
+}
+
+func fromProtoMessageEdit(edit *pb.MessageEdit) model.MessageEdit {
+	return model.MessageEdit{
+		MessageID:      edit.GetMessageId(),
+		ConversationID: edit.GetConversationId(),
+		Sender:         edit.GetSender(),
+		Content:        edit.GetContent(),
+		EditedAt:       fromTimestamp(edit.GetEditedAt()),
+	}
+}
+
+func fromProtoMessageDelete(deletion *pb.MessageDelete) model.MessageDelete {
+	return model.MessageDelete{
+		MessageID:      deletion.GetMessageId(),
+		ConversationID: deletion.GetConversationId(),
+		Scope:          deletion.GetScope(),
+		UserID:         deletion.GetUserId(),
+		DeletedAt:      fromTimestamp(deletion.GetDeletedAt()),
+	}
+}
+
+// toTimestamp leaves zero times unset, as the JSON events omit them.
+func toTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func fromTimestamp(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime().UTC()
+}
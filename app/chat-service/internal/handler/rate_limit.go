@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/config"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/model"
+	"github.com/nsmsb/darda-chat/app/chat-service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Limits are shared by replicas, so a failing limiter lets clients through rather than refusing every event and connection.
+
+// limitEvent counts an event of the user against the limit of its type, across all of the user's connections.
+func (handler *MessageHandler) limitEvent(ctx context.Context, userId string, eventType string) error {
+	config, _ := config.Get()
+	limit, exists := config.RateLimitEvents[eventType]
+	if !exists {
+		return nil
+	}
+	allowed, retryAfter, err := handler.rateLimiter.Allow(ctx, fmt.Sprintf("event:%s:%s", eventType, userId), limit)
+	if err != nil {
+		logger.GetLogger().Error("Failed to rate limit event", zap.String("user_id", userId), zap.Error(err))
+		return nil
+	}
+	if !allowed {
+		return &eventError{code: model.ErrorCodeRateLimited, message: fmt.Sprintf("too many %s events", eventType), retryAfter: retryAfter}
+	}
+	return nil
+}
+
+// isRateLimited reports whether the error refused an event over its limit.
+func isRateLimited(err error) bool {
+	var evErr *eventError
+	return errors.As(err, &evErr) && evErr.code == model.ErrorCodeRateLimited
+}
+
+// abusive counts an event of the user refused over its limit, reporting whether the user keeps sending too many
+// and must be disconnected.
+func (handler *MessageHandler) abusive(ctx context.Context, userId string) bool {
+	config, _ := config.Get()
+	allowed, _, err := handler.rateLimiter.Allow(ctx, fmt.Sprintf("violations:%s", userId), config.RateLimitViolations)
+	if err != nil {
+		logger.GetLogger().Error("Failed to count rate limit violation", zap.String("user_id", userId), zap.Error(err))
+		return false
+	}
+	return !allowed
+}
+
+// acquireConnection limits the connection attempts of the client IP and the concurrent connections of the user,
+// answering 429 if refused. It returns the function to call once the connection is closed.
+func (handler *MessageHandler) acquireConnection(c *gin.Context, userId string) (func(), bool) {
+	// Prepare logger from context
+	log := logger.GetFromContext(c)
+	ctx := c.Request.Context()
+	config, _ := config.Get()
+
+	allowed, retryAfter, err := handler.rateLimiter.Allow(ctx, fmt.Sprintf("connect:%s", c.ClientIP()), config.RateLimitConnections)
+	if err != nil {
+		log.Error("Failed to rate limit connection", zap.String("ip", c.ClientIP()), zap.Error(err))
+		allowed = true
+	}
+	if !allowed {
+		c.Header("Retry-After", retryAfterSeconds(retryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error": "too many connection attempts",
+		})
+		return nil, false
+	}
+
+	key := fmt.Sprintf("connections:%s", userId)
+	slot, acquired, err := handler.rateLimiter.Acquire(ctx, key, config.MaxConnectionsPerUser)
+	if err != nil {
+		log.Error("Failed to limit connections", zap.String("user_id", userId), zap.Error(err))
+		return func() {}, true
+	}
+	if !acquired {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error": "too many connections",
+		})
+		return nil, false
+	}
+	return func() {
+		// The request may be over when the connection is closed, as for poll sessions
+		if err := handler.rateLimiter.Release(context.Background(), key, slot); err != nil {
+			logger.GetLogger().Error("Failed to release connection", zap.String("user_id", userId), zap.Error(err))
+		}
+	}, true
+}
+
+// retryAfterSeconds formats the delay of a Retry-After header, rounded up to the second.
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
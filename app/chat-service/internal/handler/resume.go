@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/config"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/model"
+	"go.uber.org/zap"
+)
+
+// maxResumeCursors is the maximum number of conversations a client can resume at once.
+const maxResumeCursors = 100
+
+// parseResumeCursors validates the "timestamp_ID" cursors of the last message the client saw in each conversation.
+func parseResumeCursors(cursors []string) error {
+	if len(cursors) > maxResumeCursors {
+		return fmt.Errorf("more than %d resume cursors", maxResumeCursors)
+	}
+	for _, cursor := range cursors {
+		ts, id, ok := strings.Cut(cursor, "_")
+		if !ok || id == "" {
+			return fmt.Errorf("invalid resume cursor %q", cursor)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, ts); err != nil {
+			return fmt.Errorf("invalid resume cursor %q", cursor)
+		}
+	}
+	return nil
+}
+
+// maxDeviceLength is the maximum length of the device ID a client reconnects with.
+const maxDeviceLength = 64
+
+// parseDevice validates the ID of the device a client connects from, under which its stream offset is kept.
+func parseDevice(device string) error {
+	if len(device) > maxDeviceLength {
+		return fmt.Errorf("device longer than %d characters", maxDeviceLength)
+	}
+	if strings.ContainsFunc(device, func(r rune) bool { return r <= ' ' || r == ':' || r > '~' }) {
+		return fmt.Errorf("invalid device %q", device)
+	}
+	return nil
+}
+
+// replayMessages writes to the client the messages written after each cursor, oldest first, then a Resumed event.
+// It returns the IDs of the replayed messages, so the live events received meanwhile are not forwarded twice.
+func (handler *MessageHandler) replayMessages(ctx context.Context, log *zap.Logger, userId string, cursors []string, w eventWriter) map[string]struct{} {
+
+	replayed := make(map[string]struct{})
+	resumed := model.Resumed{Conversations: []model.ResumedConversation{}}
+	for _, cursor := range cursors {
+		conversation, err := handler.replayConversation(ctx, userId, cursor, w, replayed)
+		if err != nil {
+			log.Error("Failed to replay messages", zap.String("user_id", userId), zap.String("cursor", cursor), zap.Error(err))
+			if errors.Is(err, errSocketClosed) || ctx.Err() != nil {
+				return replayed
+			}
+			// Reporting the cursor that can't be resumed, and what was replayed of it
+			handler.writeError(log, w, "", err)
+			if conversation.ConversationID == "" {
+				continue
+			}
+		}
+		resumed.Conversations = append(resumed.Conversations, conversation)
+	}
+
+	if err := sendEvent(ctx, w, model.EventTypeResumed, resumed); err != nil {
+		log.Info("Failed to write resumed event", zap.String("user_id", userId), zap.Error(err))
+	}
+	return replayed
+}
+
+// replayConversation replays the messages of the conversation of the cursor written after it, up to the configured maximum.
+// The conversation is the one of the message the cursor points to, which the user must take part in.
+func (handler *MessageHandler) replayConversation(ctx context.Context, userId string, cursor string, w eventWriter, replayed map[string]struct{}) (model.ResumedConversation, error) {
+	_, messageID, _ := strings.Cut(cursor, "_")
+	last, err := handler.messageReaderService.GetMessage(ctx, messageID)
+	if err != nil {
+		return model.ResumedConversation{}, fmt.Errorf("failed to get resumed message: %w", err)
+	}
+	members, err := handler.conversationMembers(ctx, last.ConversationID)
+	if err != nil {
+		return model.ResumedConversation{}, err
+	}
+	if !slices.Contains(members, userId) {
+		return model.ResumedConversation{}, fmt.Errorf("user %s of conversation %s: %w", userId, last.ConversationID, errNotParticipant)
+	}
+
+	config, _ := config.Get()
+	resumed := model.ResumedConversation{
+		ConversationID: last.ConversationID,
+		After:          cursor,
+	}
+	for {
+		page, err := handler.messageReaderService.GetMessages(ctx, resumed.ConversationID, userId, "", resumed.After)
+		if err != nil {
+			return resumed, fmt.Errorf("failed to get messages: %w", err)
+		}
+		// No cursor once there is no message left, hidden messages included
+		if page.After == "" {
+			return resumed, nil
+		}
+		for _, msg := range page.Messages {
+			if resumed.Replayed >= config.ResumeMaxMessages {
+				resumed.Truncated = true
+				return resumed, nil
+			}
+			if err := sendEvent(ctx, w, model.EventTypeMessage, msg); err != nil {
+				return resumed, err
+			}
+			replayed[msg.ID] = struct{}{}
+			resumed.Replayed++
+			resumed.After = fmt.Sprintf("%s_%s", msg.Timestamp.Format(time.RFC3339Nano), msg.ID)
+		}
+		// Skipping the messages the user hid at the end of the page
+		resumed.After = page.After
+	}
+}
+
+// isReplayed reports whether a live event is a message already replayed to the socket.
+func isReplayed(raw string, replayed map[string]struct{}) bool {
+	var event model.Event
+	if err := json.Unmarshal([]byte(raw), &event); err != nil || event.Type != model.EventTypeMessage {
+		return false
+	}
+	var msg model.Message
+	if err := json.Unmarshal(event.Content, &msg); err != nil {
+		return false
+	}
+	_, ok := replayed[msg.ID]
+	return ok
+}
@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nsmsb/darda-chat/app/chat-service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+var (
+	// errSocketClosed is returned when writing to a client that is disconnecting or disconnected.
+	errSocketClosed = errors.New("socket closed")
+	// errQueueFull is returned when the client doesn't read its frames fast enough, it is then disconnected.
+	errQueueFull = errors.New("outbound queue full")
+)
+
+// socket owns the writes to a WebSocket, gorilla connections support a single concurrent writer.
+// Frames are queued and written by one write pump goroutine, which also pings the client.
+// Events are given as JSON and encoded in the subprotocol negotiated with the client before being queued.
+type socket struct {
+	ws         *websocket.Conn
+	codec      eventCodec
+	send       chan frame
+	writeWait  time.Duration
+	pongWait   time.Duration
+	pingPeriod time.Duration
+	closeCode  int
+	closeText  string
+	done       chan struct{} // Closed when the socket starts closing
+	stopped    chan struct{} // Closed when the write pump exits
+	closeOnce  sync.Once
+}
+
+// newSocket starts the write pump of the WebSocket and the read deadline the client must renew by answering pings.
+func newSocket(ws *websocket.Conn, codec eventCodec, writeWait, pongWait, pingPeriod time.Duration, queueSize int) *socket {
+	s := &socket{
+		ws:         ws,
+		codec:      codec,
+		send:       make(chan frame, queueSize),
+		writeWait:  writeWait,
+		pongWait:   pongWait,
+		pingPeriod: pingPeriod,
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+
+	// Pongs and any other frame from the client prove the connection is alive
+	s.Touch()
+	ws.SetPongHandler(func(string) error {
+		return s.Touch()
+	})
+
+	go s.writePump()
+	return s
+}
+
+// Touch extends the read deadline, the connection times out if the client stays silent for pongWait.
+func (s *socket) Touch() error {
+	return s.ws.SetReadDeadline(time.Now().Add(s.pongWait))
+}
+
+// WriteMessage queues a frame, the socket is closed if the queue is full.
+func (s *socket) WriteMessage(data []byte) error {
+	return s.WriteFrame(frame{data: data})
+}
+
+func (s *socket) WriteFrame(f frame) error {
+	select {
+	case <-s.done:
+		return errSocketClosed
+	default:
+	}
+	f, ok := s.encode(f)
+	if !ok {
+		return nil
+	}
+
+	select {
+	case s.send <- f:
+		return nil
+	case <-s.done:
+		return errSocketClosed
+	default:
+		s.shutdown(websocket.CloseTryAgainLater, "outbound queue full")
+		return errQueueFull
+	}
+}
+
+// Send queues a frame, waiting for room in the queue instead of closing the socket when it is full.
+func (s *socket) Send(ctx context.Context, data []byte) error {
+	return s.SendFrame(ctx, frame{data: data})
+}
+
+func (s *socket) SendFrame(ctx context.Context, f frame) error {
+	f, ok := s.encode(f)
+	if !ok {
+		return nil
+	}
+	select {
+	case s.send <- f:
+		return nil
+	case <-s.done:
+		return errSocketClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// encode turns a JSON event into a frame of the subprotocol.
+// Events the subprotocol can't carry are dropped rather than closing the socket.
+func (s *socket) encode(f frame) (frame, bool) {
+	data, err := s.codec.Encode(f.data)
+	if err != nil {
+		logger.GetLogger().Error("Failed to encode event", zap.Error(err))
+		f.sent()
+		return frame{}, false
+	}
+	f.data = data
+	return f, true
+}
+
+// Done is closed when the socket starts closing.
+func (s *socket) Done() <-chan struct{} {
+	return s.done
+}
+
+// Closing reports whether the socket started closing, after a failed write, a full queue or being drained.
+func (s *socket) Closing() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Disconnect asks the client to reconnect, once the queued frames are flushed.
+func (s *socket) Disconnect() {
+	s.shutdown(websocket.CloseTryAgainLater, "subscription closed")
+}
+
+// Close flushes the queued frames, sends a close frame with the given code and closes the connection.
+// Only the first code is sent if the socket was already closing.
+func (s *socket) Close(code int, text string) {
+	s.shutdown(code, text)
+	<-s.stopped
+}
+
+// shutdown asks the write pump to close the socket without waiting for it.
+func (s *socket) shutdown(code int, text string) {
+	s.closeOnce.Do(func() {
+		s.closeCode = code
+		s.closeText = text
+		close(s.done)
+	})
+}
+
+// writePump writes queued frames and pings until the socket is closed or a write fails.
+func (s *socket) writePump() {
+	ticker := time.NewTicker(s.pingPeriod)
+	defer func() {
+		ticker.Stop()
+		// A failed write closes the socket too, and unblocks the reader
+		s.shutdown(websocket.CloseAbnormalClosure, "")
+		s.ws.Close()
+		close(s.stopped)
+	}()
+
+	for {
+		select {
+		case f := <-s.send:
+			if err := s.write(f.data); err != nil {
+				return
+			}
+			f.sent()
+		case <-ticker.C:
+			if err := s.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.writeWait)); err != nil {
+				return
+			}
+		case <-s.done:
+			// Flushing what was queued before closing
+			if err := s.flush(); err != nil {
+				return
+			}
+			s.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(s.closeCode, s.closeText), time.Now().Add(s.writeWait))
+			return
+		}
+	}
+}
+
+// flush writes the queued frames, all within a single write deadline.
+func (s *socket) flush() error {
+	s.ws.SetWriteDeadline(time.Now().Add(s.writeWait))
+	for {
+		select {
+		case f := <-s.send:
+			if err := s.ws.WriteMessage(s.codec.FrameType(), f.data); err != nil {
+				return err
+			}
+			f.sent()
+		default:
+			return nil
+		}
+	}
+}
+
+func (s *socket) write(data []byte) error {
+	s.ws.SetWriteDeadline(time.Now().Add(s.writeWait))
+	return s.ws.WriteMessage(s.codec.FrameType(), data)
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/config"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/middleware"
+	"github.com/nsmsb/darda-chat/app/chat-service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// StreamEvents handles Server-Sent Events requests, streaming to clients that can't use WebSockets the events
+// a WebSocket would receive. Resume cursors and device are accepted as for WebSockets.
+func (handler *MessageHandler) StreamEvents(c *gin.Context) {
+	// Prepare logger from context
+	log := logger.GetFromContext(c)
+	ctx := c.Request.Context()
+
+	userId := c.GetString(middleware.UserIDKey)
+	resume := c.QueryArray("resume")
+	if err := parseResumeCursors(resume); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	device := c.Query("device")
+	if err := parseDevice(device); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Limiting connection attempts and concurrent connections
+	release, ok := handler.acquireConnection(c, userId)
+	if !ok {
+		return
+	}
+	defer release()
+
+	// Ending the stream when the replica shuts down, the client reconnecting to another one
+	config, _ := config.Get()
+	queue := newEventQueue(config.WSSendQueueSize)
+	untrack, ok := handler.drainer.Track(queue.Close)
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "shutting down",
+		})
+		return
+	}
+	log.Info("User connected to event stream", zap.String("user_id", userId))
+	defer func() {
+		queue.Close()
+		untrack()
+		log.Info("Event stream closed", zap.String("user_id", userId))
+	}()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Proxies must not buffer the stream
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	// Reading received messages
+	go handler.forwardMessages(ctx, log, userId, device, queue, resume)
+
+	// Events are written as they come, comments keep idle streams from being closed by proxies
+	keepalive := time.NewTicker(config.SSEKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case f := <-queue.send:
+			if _, err := fmt.Fprintf(c.Writer, "data: %s\n\n", f.data); err != nil {
+				return
+			}
+			c.Writer.Flush()
+			f.sent()
+		case <-keepalive.C:
+			if _, err := io.WriteString(c.Writer, ": keepalive\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-queue.Done():
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
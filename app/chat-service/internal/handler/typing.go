@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"sync"
+	"time"
+)
+
+// typingTracker keeps the typing state of a single connection, expiring it after a period of silence
+// so crashed or disconnected clients don't stay "typing" forever.
+type typingTracker struct {
+	timeout time.Duration
+	timers  map[string]*time.Timer // Expiry timers by conversation ID
+	idle    func()                 // Called when the last typing state expires, if set
+	m       sync.Mutex
+}
+
+func newTypingTracker(timeout time.Duration) *typingTracker {
+	return &typingTracker{
+		timeout: timeout,
+		timers:  make(map[string]*time.Timer),
+	}
+}
+
+// Start marks the conversation as typing, or extends it, onExpire is called if it is not renewed before the timeout.
+func (t *typingTracker) Start(conversationID string, onExpire func()) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	if timer, exists := t.timers[conversationID]; exists {
+		timer.Stop()
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(t.timeout, func() {
+		t.m.Lock()
+		// Ignoring the expiry if the state has been renewed or stopped meanwhile
+		if t.timers[conversationID] != timer {
+			t.m.Unlock()
+			return
+		}
+		delete(t.timers, conversationID)
+		idle := len(t.timers) == 0 && t.idle != nil
+		t.m.Unlock()
+		onExpire()
+		if idle {
+			t.idle()
+		}
+	})
+	t.timers[conversationID] = timer
+}
+
+// Stop clears the typing state of the conversation and reports whether it was set.
+func (t *typingTracker) Stop(conversationID string) bool {
+	t.m.Lock()
+	defer t.m.Unlock()
+	timer, exists := t.timers[conversationID]
+	if !exists {
+		return false
+	}
+	timer.Stop()
+	delete(t.timers, conversationID)
+	return true
+}
+
+// Len returns the number of conversations still typing.
+func (t *typingTracker) Len() int {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return len(t.timers)
+}
+
+// StopAll clears every typing state and returns the conversations that were still typing.
+func (t *typingTracker) StopAll() []string {
+	t.m.Lock()
+	defer t.m.Unlock()
+	conversations := make([]string, 0, len(t.timers))
+	for conversationID, timer := range t.timers {
+		timer.Stop()
+		conversations = append(conversations, conversationID)
+	}
+	clear(t.timers)
+	return conversations
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testTypingTimeout = 30 * time.Millisecond
+
+// typingStep is an action on a typing tracker, start, stop or wait for the timeout to pass.
+type typingStep struct {
+	action         string
+	conversationID string
+	wantStopped    bool // Result of a stop
+}
+
+func TestTypingTracker(t *testing.T) {
+	tests := []struct {
+		name        string
+		steps       []typingStep
+		wantExpired []string // Conversations whose typing state expired, in order
+		wantTyping  []string // Conversations still typing at the end, returned by StopAll
+		wantIdle    int      // Calls of the idle function
+	}{
+		{
+			name:        "expires without renewal",
+			steps:       []typingStep{{action: "start", conversationID: "c1"}, {action: "wait"}},
+			wantExpired: []string{"c1"},
+			wantIdle:    1,
+		},
+		{
+			name: "renewal extends the state",
+			steps: []typingStep{
+				{action: "start", conversationID: "c1"},
+				{action: "start", conversationID: "c1"},
+			},
+			wantTyping: []string{"c1"},
+		},
+		{
+			name: "stopped before expiry",
+			steps: []typingStep{
+				{action: "start", conversationID: "c1"},
+				{action: "stop", conversationID: "c1", wantStopped: true},
+				{action: "wait"},
+			},
+		},
+		{
+			name:  "stopping a conversation not typing",
+			steps: []typingStep{{action: "stop", conversationID: "c1", wantStopped: false}},
+		},
+		{
+			name: "idle once the last state expires",
+			steps: []typingStep{
+				{action: "start", conversationID: "c1"},
+				{action: "start", conversationID: "c2"},
+				{action: "stop", conversationID: "c1", wantStopped: true},
+				{action: "wait"},
+			},
+			wantExpired: []string{"c2"},
+			wantIdle:    1,
+		},
+		{
+			name: "states left at the end",
+			steps: []typingStep{
+				{action: "start", conversationID: "c1"},
+				{action: "wait"},
+				{action: "start", conversationID: "c2"},
+				{action: "start", conversationID: "c3"},
+			},
+			wantExpired: []string{"c1"},
+			wantTyping:  []string{"c2", "c3"},
+			wantIdle:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newTypingTracker(testTypingTimeout)
+			var m sync.Mutex
+			var expired []string
+			idle := 0
+			tracker.idle = func() {
+				m.Lock()
+				defer m.Unlock()
+				idle++
+			}
+
+			for _, step := range tt.steps {
+				switch step.action {
+				case "start":
+					conversationID := step.conversationID
+					tracker.Start(conversationID, func() {
+						m.Lock()
+						defer m.Unlock()
+						expired = append(expired, conversationID)
+					})
+				case "stop":
+					if stopped := tracker.Stop(step.conversationID); stopped != step.wantStopped {
+						t.Fatalf("Stop(%q) = %v, want %v", step.conversationID, stopped, step.wantStopped)
+					}
+				case "wait":
+					time.Sleep(3 * testTypingTimeout)
+				}
+			}
+
+			if n := tracker.Len(); n != len(tt.wantTyping) {
+				t.Fatalf("Len() = %d, want %d", n, len(tt.wantTyping))
+			}
+			typing := tracker.StopAll()
+			slices.Sort(typing)
+			if !slices.Equal(typing, tt.wantTyping) && len(typing)+len(tt.wantTyping) > 0 {
+				t.Fatalf("StopAll() = %v, want %v", typing, tt.wantTyping)
+			}
+			// States cleared by StopAll don't expire afterwards
+			time.Sleep(3 * testTypingTimeout)
+
+			m.Lock()
+			defer m.Unlock()
+			if !slices.Equal(expired, tt.wantExpired) && len(expired)+len(tt.wantExpired) > 0 {
+				t.Fatalf("expired %v, want %v", expired, tt.wantExpired)
+			}
+			if idle != tt.wantIdle {
+				t.Fatalf("idle called %d times, want %d", idle, tt.wantIdle)
+			}
+		})
+	}
+}
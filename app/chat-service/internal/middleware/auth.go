@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/auth"
+	"github.com/nsmsb/darda-chat/app/chat-service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const UserIDKey = "user_id"
+
+// AuthMiddleware authenticates requests with a bearer token and stores the user ID in the Gin context.
+// The token is read from the Authorization header, or from the access_token query parameter
+// since browsers can't set headers on WebSocket handshakes.
+func AuthMiddleware(authenticator auth.Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logger.GetFromContext(c)
+
+		token := c.Query("access_token")
+		if header := c.GetHeader("Authorization"); header != "" {
+			scheme, value, _ := strings.Cut(header, " ")
+			if !strings.EqualFold(scheme, "Bearer") {
+				abortUnauthorized(c, "unsupported authorization scheme")
+				return
+			}
+			token = strings.TrimSpace(value)
+		}
+
+		userId, err := authenticator.Authenticate(c.Request.Context(), token)
+		switch {
+		case errors.Is(err, auth.ErrMissingToken):
+			abortUnauthorized(c, "missing token")
+			return
+		case errors.Is(err, auth.ErrInvalidToken):
+			log.Info("Rejected invalid token", zap.Error(err))
+			abortUnauthorized(c, "invalid token")
+			return
+		case err != nil:
+			log.Error("Failed to authenticate request", zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error": "authentication unavailable",
+			})
+			return
+		}
+
+		c.Set(UserIDKey, userId)
+		c.Next()
+	}
+}
+
+func abortUnauthorized(c *gin.Context, reason string) {
+	c.Header("WWW-Authenticate", `Bearer realm="darda-chat"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+		"error": reason,
+	})
+}
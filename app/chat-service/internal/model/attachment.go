@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// Represents the metadata of a file attached to a message, set by the server from the uploaded file
+type Attachment struct {
+	ID       string `json:"id"`
+	Name     string `json:"name,omitempty"`
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"`         // Hex encoded SHA-256 of the content
+	Width    int    `json:"width,omitempty"`  // Dimensions of images
+	Height   int    `json:"height,omitempty"` // Dimensions of images
+}
+
+// Represents an uploaded attachment with the conversation it was uploaded to, only its participants can fetch it
+type StoredAttachment struct {
+	Attachment
+	ConversationID string    `json:"conversation_id"`
+	UploadedBy     string    `json:"uploaded_by"`
+	UploadedAt     time.Time `json:"uploaded_at"`
+}
@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// Represents a user blocking or unblocking another user
+type Block struct {
+	UserID        string    `json:"user_id"` // User who blocks, always the authenticated user
+	BlockedUserID string    `json:"blocked_user_id" binding:"required"`
+	Blocked       bool      `json:"blocked"` // False when unblocking
+	UpdatedAt     time.Time `json:"updated_at"`
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// Represents a conversation in the inbox of a user
+type Conversation struct {
+	ConversationID string                `json:"conversation_id"`
+	LastMessage    *Message              `json:"last_message,omitempty"` // Content truncated, absent for conversations without messages
+	LastActivityAt time.Time             `json:"last_activity_at"`
+	UnreadCount    int64                 `json:"unread_count"`
+	LastRead       *ReceiptPosition      `json:"last_read,omitempty"`     // Absent if the user never read the conversation
+	Notifications  *NotificationSettings `json:"notifications,omitempty"` // Absent if the user never changed them, notifying of all messages
+}
+
+// Represents a page of the inbox of a user, with the cursor of the next page
+type ConversationPage struct {
+	Conversations []*Conversation
+	Before        string
+}
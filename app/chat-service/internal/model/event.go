@@ -7,14 +7,25 @@ import (
 
 const (
 	// Event types
-	EventTypeMessage      = "Message"
-	EventTypeMessageEvent = "MessageEvent"
+	EventTypeMessage              = "Message"
+	EventTypeMessageEvent         = "MessageEvent"
+	EventTypeMessageEdit          = "MessageEdit"
+	EventTypeMessageDelete        = "MessageDelete"
+	EventTypeGroup                = "Group"
+	EventTypeBlock                = "Block"                // Published to the message-writer-service only, never sent to clients
+	EventTypeNotificationSettings = "NotificationSettings" // Published to the message-writer-service only, never sent to clients
+	EventTypePresence             = "Presence"
+	EventTypeUnreadCount          = "UnreadCount"
+	EventTypeResumed              = "Resumed"
+	EventTypeAck                  = "Ack"
+	EventTypeError                = "Error"
 )
 
 // Represents a generic event wrapper
 type Event struct {
-	Type      string          `json:"type"`      // "Message", "MessageEvent"
-	EventID   string          `json:"event_id"`  // Unique ID for the event
-	Timestamp time.Time       `json:"timestamp"` // Timestamp when the event was created
-	Content   json.RawMessage `json:"content"`   // Raw JSON, to decode later depending on Type
+	Type      string          `json:"type"`                 // "Message", "MessageEvent", "MessageEdit", "MessageDelete", "Group", "Presence", "UnreadCount", "Resumed", "Ack", "Error"
+	EventID   string          `json:"event_id"`             // Unique ID for the event
+	RequestID string          `json:"request_id,omitempty"` // Client-supplied ID echoed in the Ack or Error frame, never forwarded
+	Timestamp time.Time       `json:"timestamp"`            // Timestamp when the event was created
+	Content   json.RawMessage `json:"content"`              // Raw JSON, to decode later depending on Type
 }
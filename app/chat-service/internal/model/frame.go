@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+const (
+	// Error codes of Error frames, stable so clients can act on them
+	ErrorCodeInvalidEvent     = "invalid_event"     // Malformed event or missing fields
+	ErrorCodeUnsupportedEvent = "unsupported_event" // Unknown event type
+	ErrorCodeForbidden        = "forbidden"         // User not allowed to act on the conversation
+	ErrorCodeNotFound         = "not_found"         // Conversation, message or attachment doesn't exist
+	ErrorCodeRateLimited      = "rate_limited"      // Too many events, the client must wait before sending more
+	ErrorCodeDeliveryFailed   = "delivery_failed"   // Message refused for its recipient, retrying won't help
+	ErrorCodeInternal         = "internal_error"    // Server side failure, the client may retry
+)
+
+// Acknowledges an event sent by the client once it is accepted
+type Ack struct {
+	RequestID      string    `json:"request_id"`
+	MessageID      string    `json:"message_id,omitempty"` // Server-assigned ID, only for messages
+	ConversationID string    `json:"conversation_id,omitempty"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// Reports to the client that one of its events was refused or failed
+type Error struct {
+	RequestID  string `json:"request_id,omitempty"` // Empty if the event couldn't be decoded
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	RetryAfter int64  `json:"retry_after_ms,omitempty"` // Milliseconds to wait before sending again, only for rate limited events
+}
+
+// Reports the end of the replay of the messages missed while disconnected, live events follow it
+type Resumed struct {
+	Conversations []ResumedConversation `json:"conversations"`
+}
+
+// Reports the messages replayed for a conversation, and the cursor to resume from next time
+type ResumedConversation struct {
+	ConversationID string `json:"conversation_id"`
+	After          string `json:"after"`               // Cursor of the last message replayed, or the given one if none was
+	Replayed       int    `json:"replayed"`            // Number of messages replayed
+	Truncated      bool   `json:"truncated,omitempty"` // More messages are left, to be fetched from the after cursor
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// Represents a group conversation and its members
+type Group struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name" binding:"required"`
+	Members   []string  `json:"members" binding:"required"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -4,10 +4,41 @@ import "time"
 
 // Represents an actual chat message
 type Message struct {
-	ID             string    `json:"id"`
-	ConversationID string    `json:"conversation_id"`
-	Sender         string    `json:"sender" binding:"required"`
-	Destination    string    `json:"destination" binding:"required"`
-	Content        string    `json:"content" binding:"required"`
-	Timestamp      time.Time `json:"timestamp"`
+	ID             string       `json:"id"`
+	ConversationID string       `json:"conversation_id"`
+	Sender         string       `json:"sender" binding:"required"`
+	Destination    string       `json:"destination"` // Empty for group messages, the group is identified by ConversationID
+	Content        string       `json:"content"`     // May be empty for messages with attachments
+	Timestamp      time.Time    `json:"timestamp"`
+	EditedAt       time.Time    `json:"edited_at,omitzero"`  // Set by the server when the message is edited
+	DeletedAt      time.Time    `json:"deleted_at,omitzero"` // Set by the server when the message is deleted for everyone
+	DeletedBy      string       `json:"deleted_by,omitempty"`
+	Reactions      []Reaction   `json:"reactions,omitempty"`      // Set by the server
+	ReplyTo        string       `json:"reply_to,omitempty"`       // Message quoted by this one, of the same conversation
+	ThreadRootID   string       `json:"thread_root_id,omitempty"` // Root of the thread the message replies in
+	ReplyCount     int64        `json:"reply_count,omitempty"`    // Set by the server on root messages of threads
+	LastReplyAt    time.Time    `json:"last_reply_at,omitzero"`   // Set by the server on root messages of threads
+	Attachments    []Attachment `json:"attachments,omitempty"`    // Uploaded beforehand, only their IDs are read from clients
+	Mentions       []string     `json:"mentions,omitempty"`       // Participants mentioned by the message
+}
+
+// Represents the users who reacted to a message with the same reaction
+type Reaction struct {
+	Reaction string   `json:"reaction"`
+	Users    []string `json:"users"`
+}
+
+// Represents a page of a conversation history, with the cursors of the older and newer pages
+type MessagePage struct {
+	Messages []*Message
+	Before   string
+	After    string
+}
+
+// Represents a root message and a page of the replies in its thread, with the cursors of the older and newer pages
+type ThreadPage struct {
+	Root     *Message
+	Messages []*Message
+	Before   string
+	After    string
 }
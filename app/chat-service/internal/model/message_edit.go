@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// Represents the new content of a message, only its sender can edit it
+type MessageEdit struct {
+	MessageID      string    `json:"message_id"`
+	ConversationID string    `json:"conversation_id"` // Set by the server
+	Sender         string    `json:"sender"`          // Set by the server
+	Content        string    `json:"content"`
+	EditedAt       time.Time `json:"edited_at"` // Set by the server
+}
@@ -2,8 +2,24 @@ package model
 
 import "time"
 
+const (
+	// Message event types
+	MessageEventDelivered       = "delivered"
+	MessageEventRead            = "read"
+	MessageEventTypingStarted   = "typing_started" // Ephemeral, only relayed in real time
+	MessageEventTypingStopped   = "typing_stopped" // Ephemeral, only relayed in real time
+	MessageEventReactionAdded   = "reaction_added"
+	MessageEventReactionRemoved = "reaction_removed"
+)
+
 // Represents message-related events (e.g. delivered, read, typing)
 type MessageEvent struct {
-	ConversationID string    `json:"conversation_id"`
-	Timestamp      time.Time `json:"timestamp"`
+	Type             string    `json:"type"`              // "delivered", "read", "typing_started", "typing_stopped", "reaction_added", "reaction_removed"
+	MessageID        string    `json:"message_id"`        // Message the event refers to
+	MessageTimestamp time.Time `json:"message_timestamp"` // Timestamp of the message, used to order positions
+	ConversationID   string    `json:"conversation_id"`
+	Sender           string    `json:"sender"`             // Sender of the message, the event is relayed to them
+	UserID           string    `json:"user_id"`            // User who reported the event, set by the server
+	Reaction         string    `json:"reaction,omitempty"` // Only for reaction events, e.g. an emoji
+	Timestamp        time.Time `json:"timestamp"`
 }
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+const (
+	// Notification levels of a conversation
+	NotifyAll      = "all"
+	NotifyMentions = "mentions" // Only messages mentioning the user
+	NotifyMuted    = "muted"    // No message until MutedUntil, or until changed if unset
+)
+
+// Represents how a user is notified of the messages of a conversation while not looking at it
+type NotificationSettings struct {
+	UserID         string    `json:"user_id"` // Always the authenticated user
+	ConversationID string    `json:"conversation_id"`
+	Level          string    `json:"level" binding:"required,oneof=all mentions muted"`
+	MutedUntil     time.Time `json:"muted_until,omitzero"` // Only for the muted level, notifying of all messages once past
+	UpdatedAt      time.Time `json:"updated_at,omitzero"`  // Absent if never changed
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+const (
+	// Presence statuses
+	PresenceOnline  = "online"
+	PresenceOffline = "offline"
+)
+
+// Represents the online state of a user
+type Presence struct {
+	UserID   string    `json:"user_id"`
+	Status   string    `json:"status"`    // "online", "offline"
+	LastSeen time.Time `json:"last_seen"` // Last time the user was seen online, zero if never seen
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// Represents the latest delivery and read positions of a participant in a conversation
+type Receipt struct {
+	ConversationID string           `json:"conversation_id"`
+	UserID         string           `json:"user_id"`
+	Delivered      *ReceiptPosition `json:"delivered,omitempty"`
+	Read           *ReceiptPosition `json:"read,omitempty"`
+}
+
+// Represents the last message a receipt was reported for
+type ReceiptPosition struct {
+	MessageID        string    `json:"message_id"`
+	MessageTimestamp time.Time `json:"message_timestamp"`
+	ReportedAt       time.Time `json:"reported_at"`
+}
@@ -0,0 +1,9 @@
+package model
+
+import "encoding/json"
+
+// Wraps an event published to the channel of a replica, with the users of that replica it is for
+type RoutedEvent struct {
+	Users []string        `json:"users"`
+	Event json.RawMessage `json:"event"`
+}
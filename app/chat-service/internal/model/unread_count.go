@@ -0,0 +1,7 @@
+package model
+
+// Represents the number of messages of a conversation the user didn't read, pushed by the message-writer-service when it changes
+type UnreadCount struct {
+	ConversationID string `json:"conversation_id"`
+	UnreadCount    int64  `json:"unread_count"`
+}
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/model"
+)
+
+var (
+	// ErrAttachmentNotFound is returned when the requested attachment does not exist.
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	// ErrAttachmentTooLarge is returned when an uploaded file exceeds the maximum attachment size.
+	ErrAttachmentTooLarge = errors.New("attachment too large")
+)
+
+// AttachmentService defines the interface for storing the files attached to messages.
+type AttachmentService interface {
+	// Upload streams a file uploaded to a conversation into storage and returns its metadata.
+	Upload(ctx context.Context, conversationID string, uploader string, name string, r io.Reader) (*model.StoredAttachment, error)
+	// GetAttachment retrieves the metadata of an attachment.
+	GetAttachment(ctx context.Context, attachmentID string) (*model.StoredAttachment, error)
+	// Open returns the content of an attachment, to be closed by the caller.
+	Open(ctx context.Context, attachmentID string) (io.ReadCloser, error)
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/model"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/storage"
+)
+
+// headerSize is the length of the start of a file read to detect its type and image dimensions.
+const headerSize = 64 << 10
+
+// maxNameLength is the length above which attachment file names are truncated.
+const maxNameLength = 255
+
+// BlobAttachmentService stores attachments in a blob store, their metadata being stored next to their content.
+type BlobAttachmentService struct {
+	store   storage.BlobStore
+	maxSize int64
+}
+
+// NewBlobAttachmentService creates a BlobAttachmentService refusing files larger than maxSize bytes.
+func NewBlobAttachmentService(store storage.BlobStore, maxSize int64) *BlobAttachmentService {
+	return &BlobAttachmentService{
+		store:   store,
+		maxSize: maxSize,
+	}
+}
+
+// Upload streams the file into the blob store, computing its checksum on the way.
+// The type is detected from the content rather than trusted from the client, as are image dimensions.
+func (s *BlobAttachmentService) Upload(ctx context.Context, conversationID string, uploader string, name string, r io.Reader) (*model.StoredAttachment, error) {
+	attachment := &model.StoredAttachment{
+		Attachment: model.Attachment{
+			ID:   uuid.New().String(),
+			Name: cleanName(name),
+		},
+		ConversationID: conversationID,
+		UploadedBy:     uploader,
+		UploadedAt:     time.Now().UTC(),
+	}
+
+	// Peeking at the start of the file without buffering it whole
+	reader := bufio.NewReaderSize(&limitedReader{r: r, remaining: s.maxSize}, headerSize)
+	header, err := reader.Peek(headerSize)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, err
+	}
+	attachment.MimeType = http.DetectContentType(header)
+	if strings.HasPrefix(attachment.MimeType, "image/") {
+		// Dimensions are left out when the image header is not in the peeked bytes
+		if config, _, err := image.DecodeConfig(bytes.NewReader(header)); err == nil {
+			attachment.Width, attachment.Height = config.Width, config.Height
+		}
+	}
+
+	hash := sha256.New()
+	size, err := s.store.Put(ctx, attachment.ID, io.TeeReader(reader, hash))
+	if err != nil {
+		return nil, err
+	}
+	attachment.Size = size
+	attachment.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	metadata, err := json.Marshal(attachment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal attachment: %w", err)
+	}
+	if _, err := s.store.Put(ctx, metadataKey(attachment.ID), bytes.NewReader(metadata)); err != nil {
+		// Not leaving content without metadata behind
+		_ = s.store.Delete(context.WithoutCancel(ctx), attachment.ID)
+		return nil, err
+	}
+	return attachment, nil
+}
+
+func (s *BlobAttachmentService) GetAttachment(ctx context.Context, attachmentID string) (*model.StoredAttachment, error) {
+	// Attachment IDs are generated UUIDs, other keys are never looked up
+	if _, err := uuid.Parse(attachmentID); err != nil {
+		return nil, ErrAttachmentNotFound
+	}
+	blob, err := s.store.Get(ctx, metadataKey(attachmentID))
+	if errors.Is(err, storage.ErrBlobNotFound) {
+		return nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+
+	var attachment model.StoredAttachment
+	if err := json.NewDecoder(blob).Decode(&attachment); err != nil {
+		return nil, fmt.Errorf("failed to decode attachment: %w", err)
+	}
+	return &attachment, nil
+}
+
+func (s *BlobAttachmentService) Open(ctx context.Context, attachmentID string) (io.ReadCloser, error) {
+	if _, err := uuid.Parse(attachmentID); err != nil {
+		return nil, ErrAttachmentNotFound
+	}
+	blob, err := s.store.Get(ctx, attachmentID)
+	if errors.Is(err, storage.ErrBlobNotFound) {
+		return nil, ErrAttachmentNotFound
+	}
+	return blob, err
+}
+
+// metadataKey returns the key of the metadata of an attachment, stored next to its content.
+func metadataKey(attachmentID string) string {
+	return attachmentID + ".json"
+}
+
+// cleanName keeps the base name of an uploaded file, without the client's directories.
+func cleanName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	if name == "." || name == "/" {
+		return ""
+	}
+	if len(name) > maxNameLength {
+		name = strings.ToValidUTF8(name[:maxNameLength], "")
+	}
+	return name
+}
+
+// limitedReader reads from r and fails with ErrAttachmentTooLarge once more than remaining bytes are read.
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, ErrAttachmentTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,16 @@
+package service
+
+import (
+	"context"
+
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/model"
+)
+
+// Deduplicator remembers the recently sent client message IDs of each sender, so retried sends are not delivered twice.
+type Deduplicator interface {
+	// Reserve records the ack of a message about to be sent, or returns the ack recorded for an earlier send
+	// of the same ID by the sender along with true.
+	Reserve(ctx context.Context, sender string, messageID string, ack model.Ack) (model.Ack, bool, error)
+	// Release forgets a message ID whose send failed, so the client can retry it.
+	Release(ctx context.Context, sender string, messageID string) error
+}
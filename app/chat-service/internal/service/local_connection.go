@@ -0,0 +1,94 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/config"
+	"github.com/nsmsb/darda-chat/app/chat-service/pkg/logger"
+)
+
+// LocalConnection distributes the events routed to a user on this replica to the user's subscribers.
+// Events are delivered by the reader of the replica's channel, which must never wait for a slow subscriber.
+type LocalConnection struct {
+	Count       int
+	Subscribers map[<-chan string]chan string
+	m           sync.Mutex
+}
+
+func NewLocalConnection() *LocalConnection {
+	return &LocalConnection{
+		Count:       0,
+		Subscribers: make(map[<-chan string]chan string),
+	}
+}
+
+// StartReading does nothing, events are pushed to the connection with Deliver.
+func (conn *LocalConnection) StartReading() {}
+
+// Deliver sends the event to every subscriber, subscribers whose buffer is full are removed.
+func (conn *LocalConnection) Deliver(msg string) {
+	conn.m.Lock()
+	defer conn.m.Unlock()
+	for ch, biCh := range conn.Subscribers {
+		select {
+		case biCh <- msg:
+			// Message sent successfully
+		default:
+			// Subscriber is not receiving messages or so slow
+			logger.GetLogger().Info("Subscriber is not receiving messages, removing subscriber")
+			close(biCh)
+			delete(conn.Subscribers, ch)
+			conn.Count--
+		}
+	}
+}
+
+// NewSubscriber adds a subscriber channel to the connection
+func (conn *LocalConnection) NewSubscriber() <-chan string {
+	// Creating a new buffered channel for the subscriber
+	// So the sender won't be blocked if the receiver is slow
+	config, _ := config.Get()
+	ch := make(chan string, config.SubsChanBufferSize)
+
+	conn.m.Lock()
+	defer conn.m.Unlock()
+	conn.Subscribers[ch] = ch
+	conn.Count++
+
+	return ch
+}
+
+// RemoveSubscriber removes a subscriber channel from the connection
+func (conn *LocalConnection) RemoveSubscriber(ch <-chan string) error {
+	conn.m.Lock()
+	defer conn.m.Unlock()
+	biCh, exists := conn.Subscribers[ch]
+	if !exists {
+		return fmt.Errorf("subscriber channel not found")
+	}
+	conn.Count--
+	close(biCh)
+	delete(conn.Subscribers, ch)
+
+	return nil
+}
+
+// SubscriberCount returns the number of active subscribers
+func (conn *LocalConnection) SubscriberCount() int {
+	conn.m.Lock()
+	defer conn.m.Unlock()
+	return conn.Count
+}
+
+// Close closes all subscriber channels
+func (conn *LocalConnection) Close() error {
+	conn.m.Lock()
+	defer conn.m.Unlock()
+	for ch, biCh := range conn.Subscribers {
+		close(biCh)
+		delete(conn.Subscribers, ch)
+		conn.Count--
+	}
+	return nil
+}
@@ -2,12 +2,36 @@ package service
 
 import (
 	"context"
+	"errors"
 
 	"github.com/nsmsb/darda-chat/app/chat-service/internal/model"
 )
 
+var (
+	// ErrGroupNotFound is returned when the requested group does not exist.
+	ErrGroupNotFound = errors.New("group not found")
+	// ErrMessageNotFound is returned when the requested message does not exist, or is not written yet.
+	ErrMessageNotFound = errors.New("message not found")
+)
+
 // MessageReader defines the interface for reading messages from the message-reader-service.
 type MessageReader interface {
-	// GetMessages retrieves messages for a given conversation ID.
-	GetMessages(ctx context.Context, conversationID string, before string, after string) ([]*model.Message, error)
+	// GetMessages retrieves a page of messages for a given conversation ID, without those the user deleted for themselves or received from users they blocked.
+	GetMessages(ctx context.Context, conversationID string, userId string, before string, after string) (*model.MessagePage, error)
+	// GetMessage retrieves a single message by its ID.
+	GetMessage(ctx context.Context, messageID string) (*model.Message, error)
+	// GetThread retrieves a root message and a page of the replies in its thread, without those the user deleted for themselves or received from users they blocked.
+	GetThread(ctx context.Context, threadRootID string, userId string, before string, after string) (*model.ThreadPage, error)
+	// ListConversations retrieves a page of the conversations of a user, most recently active first.
+	ListConversations(ctx context.Context, userId string, before string) (*model.ConversationPage, error)
+	// GetGroup retrieves a group conversation and its members.
+	GetGroup(ctx context.Context, groupID string) (*model.Group, error)
+	// GetReceipts retrieves the latest delivery and read positions of each participant of a conversation.
+	GetReceipts(ctx context.Context, conversationID string) ([]*model.Receipt, error)
+	// ListBlocks retrieves the users a user blocked, most recently blocked first.
+	ListBlocks(ctx context.Context, userId string) ([]*model.Block, error)
+	// ListBlockers retrieves the users, among the given ones, who blocked a user.
+	ListBlockers(ctx context.Context, userId string, users []string) ([]string, error)
+	// GetNotificationSettings retrieves the notification settings of a user for a conversation, all messages being notified by default.
+	GetNotificationSettings(ctx context.Context, userId string, conversationID string) (*model.NotificationSettings, error)
 }
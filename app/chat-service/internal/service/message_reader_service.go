@@ -5,6 +5,8 @@ import (
 
 	pb "github.com/nsmsb/darda-chat/app/chat-service/internal/api/message/gen"
 	"github.com/nsmsb/darda-chat/app/chat-service/internal/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type MessageReaderService struct {
@@ -17,13 +19,14 @@ func NewMessageReaderService(client pb.MessageServiceClient) *MessageReaderServi
 	}
 }
 
-// GetMessages retrieves messages for a given conversation ID using the message-reader-service.
-func (s *MessageReaderService) GetMessages(ctx context.Context, conversationID string, before string, after string) ([]*model.Message, error) {
+// GetMessages retrieves a page of messages for a given conversation ID using the message-reader-service.
+func (s *MessageReaderService) GetMessages(ctx context.Context, conversationID string, userId string, before string, after string) (*model.MessagePage, error) {
 	// Prepare request
 	req := &pb.GetMessagesRequest{
 		ConversationId: conversationID,
 		Before:         before,
 		After:          after,
+		UserId:         userId,
 	}
 	// Call gRPC method
 	resp, err := s.client.GetMessages(ctx, req)
@@ -33,15 +36,240 @@ func (s *MessageReaderService) GetMessages(ctx context.Context, conversationID s
 	// Transform response to model.Message slice, initializing empty slice to return [] instead of null when no result is found
 	messages := []*model.Message{}
 	for _, msg := range resp.Messages {
-		messages = append(messages, &model.Message{
-			ID:             msg.GetId(),
-			ConversationID: msg.GetConversationId(),
-			Sender:         msg.GetSender(),
-			Destination:    msg.GetDestination(),
-			Content:        msg.GetContent(),
-			Timestamp:      msg.GetTimestamp().AsTime().UTC(),
+		messages = append(messages, toMessage(msg))
+	}
+
+	return &model.MessagePage{
+		Messages: messages,
+		Before:   resp.GetBefore(),
+		After:    resp.GetAfter(),
+	}, nil
+}
+
+// GetMessage retrieves a single message by its ID using the message-reader-service.
+func (s *MessageReaderService) GetMessage(ctx context.Context, messageID string) (*model.Message, error) {
+	resp, err := s.client.GetMessage(ctx, &pb.GetMessageRequest{MessageId: messageID})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+	return toMessage(resp.GetMessage()), nil
+}
+
+// GetThread retrieves a root message and a page of the replies in its thread using the message-reader-service.
+func (s *MessageReaderService) GetThread(ctx context.Context, threadRootID string, userId string, before string, after string) (*model.ThreadPage, error) {
+	resp, err := s.client.GetThread(ctx, &pb.GetThreadRequest{
+		ThreadRootId: threadRootID,
+		Before:       before,
+		After:        after,
+		UserId:       userId,
+	})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+
+	// Initializing empty slice to return [] instead of null when the thread has no reply
+	replies := []*model.Message{}
+	for _, msg := range resp.GetMessages() {
+		replies = append(replies, toMessage(msg))
+	}
+
+	return &model.ThreadPage{
+		Root:     toMessage(resp.GetRoot()),
+		Messages: replies,
+		Before:   resp.GetBefore(),
+		After:    resp.GetAfter(),
+	}, nil
+}
+
+// ListConversations retrieves a page of the conversations of a user using the message-reader-service.
+func (s *MessageReaderService) ListConversations(ctx context.Context, userId string, before string) (*model.ConversationPage, error) {
+	resp, err := s.client.ListConversations(ctx, &pb.ListConversationsRequest{
+		UserId: userId,
+		Before: before,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Initializing empty slice to return [] instead of null when the user has no conversation
+	conversations := []*model.Conversation{}
+	for _, summary := range resp.GetConversations() {
+		conversation := &model.Conversation{
+			ConversationID: summary.GetConversationId(),
+			LastActivityAt: summary.GetLastActivityAt().AsTime().UTC(),
+			UnreadCount:    summary.GetUnreadCount(),
+			LastRead:       toReceiptPosition(summary.GetLastRead()),
+		}
+		if summary.GetLastMessage() != nil {
+			conversation.LastMessage = toMessage(summary.GetLastMessage())
+		}
+		if summary.GetNotifications() != nil {
+			conversation.Notifications = toNotificationSettings(summary.GetNotifications())
+		}
+		conversations = append(conversations, conversation)
+	}
+
+	return &model.ConversationPage{
+		Conversations: conversations,
+		Before:        resp.GetBefore(),
+	}, nil
+}
+
+// toMessage converts a protobuf message to model.Message.
+func toMessage(msg *pb.Message) *model.Message {
+	message := &model.Message{
+		ID:             msg.GetId(),
+		ConversationID: msg.GetConversationId(),
+		Sender:         msg.GetSender(),
+		Destination:    msg.GetDestination(),
+		Content:        msg.GetContent(),
+		Timestamp:      msg.GetTimestamp().AsTime().UTC(),
+		ReplyTo:        msg.GetReplyTo(),
+		ThreadRootID:   msg.GetThreadRootId(),
+		ReplyCount:     msg.GetReplyCount(),
+		Mentions:       msg.GetMentions(),
+	}
+	if msg.GetLastReplyAt() != nil {
+		message.LastReplyAt = msg.GetLastReplyAt().AsTime().UTC()
+	}
+	if msg.GetEditedAt() != nil {
+		message.EditedAt = msg.GetEditedAt().AsTime().UTC()
+	}
+	if msg.GetDeletedAt() != nil {
+		message.DeletedAt = msg.GetDeletedAt().AsTime().UTC()
+		message.DeletedBy = msg.GetDeletedBy()
+	}
+	for _, reaction := range msg.GetReactions() {
+		message.Reactions = append(message.Reactions, model.Reaction{
+			Reaction: reaction.GetReaction(),
+			Users:    reaction.GetUsers(),
+		})
+	}
+	for _, attachment := range msg.GetAttachments() {
+		message.Attachments = append(message.Attachments, model.Attachment{
+			ID:       attachment.GetId(),
+			Name:     attachment.GetName(),
+			MimeType: attachment.GetMimeType(),
+			Size:     attachment.GetSize(),
+			Checksum: attachment.GetChecksum(),
+			Width:    int(attachment.GetWidth()),
+			Height:   int(attachment.GetHeight()),
+		})
+	}
+	return message
+}
+
+// GetGroup retrieves a group conversation and its members using the message-reader-service.
+func (s *MessageReaderService) GetGroup(ctx context.Context, groupID string) (*model.Group, error) {
+	resp, err := s.client.GetGroup(ctx, &pb.GetGroupRequest{GroupId: groupID})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrGroupNotFound
+		}
+		return nil, err
+	}
+
+	group := resp.GetGroup()
+	return &model.Group{
+		ID:        group.GetId(),
+		Name:      group.GetName(),
+		Members:   group.GetMembers(),
+		CreatedBy: group.GetCreatedBy(),
+		CreatedAt: group.GetCreatedAt().AsTime().UTC(),
+	}, nil
+}
+
+// GetReceipts retrieves the delivery and read positions of a conversation using the message-reader-service.
+func (s *MessageReaderService) GetReceipts(ctx context.Context, conversationID string) ([]*model.Receipt, error) {
+	resp, err := s.client.GetReceipts(ctx, &pb.GetReceiptsRequest{ConversationId: conversationID})
+	if err != nil {
+		return nil, err
+	}
+
+	// Initializing empty slice to return [] instead of null when no receipt is found
+	receipts := []*model.Receipt{}
+	for _, receipt := range resp.GetReceipts() {
+		receipts = append(receipts, &model.Receipt{
+			ConversationID: receipt.GetConversationId(),
+			UserID:         receipt.GetUserId(),
+			Delivered:      toReceiptPosition(receipt.GetDelivered()),
+			Read:           toReceiptPosition(receipt.GetRead()),
 		})
 	}
+	return receipts, nil
+}
+
+// ListBlocks retrieves the blocks of a user using the message-reader-service.
+func (s *MessageReaderService) ListBlocks(ctx context.Context, userId string) ([]*model.Block, error) {
+	resp, err := s.client.ListBlocks(ctx, &pb.ListBlocksRequest{UserId: userId})
+	if err != nil {
+		return nil, err
+	}
+
+	// Initializing empty slice to return [] instead of null when no user is blocked
+	blocks := []*model.Block{}
+	for _, block := range resp.GetBlocks() {
+		blocks = append(blocks, &model.Block{
+			UserID:        block.GetUserId(),
+			BlockedUserID: block.GetBlockedUserId(),
+			Blocked:       true,
+			UpdatedAt:     block.GetBlockedAt().AsTime().UTC(),
+		})
+	}
+	return blocks, nil
+}
+
+// ListBlockers retrieves the users who blocked a user among the given ones using the message-reader-service.
+func (s *MessageReaderService) ListBlockers(ctx context.Context, userId string, users []string) ([]string, error) {
+	resp, err := s.client.ListBlockers(ctx, &pb.ListBlockersRequest{UserId: userId, Users: users})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetUsers(), nil
+}
+
+// GetNotificationSettings retrieves the notification settings of a user for a conversation using the message-reader-service.
+func (s *MessageReaderService) GetNotificationSettings(ctx context.Context, userId string, conversationID string) (*model.NotificationSettings, error) {
+	resp, err := s.client.GetNotificationSettings(ctx, &pb.GetNotificationSettingsRequest{
+		UserId:         userId,
+		ConversationId: conversationID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toNotificationSettings(resp.GetSettings()), nil
+}
 
-	return messages, nil
+// toNotificationSettings converts protobuf notification settings, keeping unset times zero.
+func toNotificationSettings(settings *pb.NotificationSettings) *model.NotificationSettings {
+	notificationSettings := &model.NotificationSettings{
+		UserID:         settings.GetUserId(),
+		ConversationID: settings.GetConversationId(),
+		Level:          settings.GetLevel(),
+	}
+	if settings.GetMutedUntil() != nil {
+		notificationSettings.MutedUntil = settings.GetMutedUntil().AsTime().UTC()
+	}
+	if settings.GetUpdatedAt() != nil {
+		notificationSettings.UpdatedAt = settings.GetUpdatedAt().AsTime().UTC()
+	}
+	return notificationSettings
+}
+
+// toReceiptPosition converts a protobuf receipt position, keeping missing positions nil.
+func toReceiptPosition(position *pb.ReceiptPosition) *model.ReceiptPosition {
+	if position == nil {
+		return nil
+	}
+	return &model.ReceiptPosition{
+		MessageID:        position.GetMessageId(),
+		MessageTimestamp: position.GetMessageTimestamp().AsTime().UTC(),
+		ReportedAt:       position.GetReportedAt().AsTime().UTC(),
+	}
 }
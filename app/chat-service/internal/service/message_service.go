@@ -3,8 +3,13 @@ package service
 import "context"
 
 type MessageService interface {
-	SendMessage(ctx context.Context, destination string, msg string) error
-	SubscribeToMessages(ctx context.Context, channel string) (<-chan string, error)
+	SendMessage(ctx context.Context, destinations []string, msg string) error
+	// SendEphemeral delivers msg to the destinations in real time only, without persisting it.
+	SendEphemeral(ctx context.Context, destinations []string, msg string) error
+	// SubscribeToMessages subscribes to the messages of a user, the device identifying where the messages are delivered.
+	SubscribeToMessages(ctx context.Context, channel string, device string) (<-chan string, error)
+	// Acknowledge reports msg received from msgCh as written to the client, or skipped, so it isn't delivered to the device again.
+	Acknowledge(channel string, msgCh <-chan string, msg string)
 	UnsubscribeFromMessages(channel string, msgCh <-chan string) error
 	Close() error
 }
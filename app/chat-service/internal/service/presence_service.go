@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/model"
+	"github.com/nsmsb/darda-chat/app/chat-service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// PresenceService tracks which users are online across all chat-service replicas.
+type PresenceService interface {
+	// Connect marks the user as online on this replica, reporting whether the user just came online.
+	Connect(ctx context.Context, userId string) (bool, error)
+	// Disconnect marks the user as gone from this replica, reporting whether the user just went offline.
+	Disconnect(ctx context.Context, userId string) (bool, error)
+	// GetPresence returns the current presence of a user.
+	GetPresence(ctx context.Context, userId string) (*model.Presence, error)
+	// AddContacts records users who exchanged messages with the user, they are notified of presence changes.
+	AddContacts(ctx context.Context, userId string, contacts []string) error
+	// Contacts returns the users to notify about the presence changes of a user.
+	Contacts(ctx context.Context, userId string) ([]string, error)
+	// OnExpired sets the function called with each user left offline by a replica that stopped heartbeating.
+	OnExpired(notify func(ctx context.Context, userId string))
+	Close() error
+}
+
+// notifyPresence pushes the current presence of the user to their contacts through the message service.
+func notifyPresence(ctx context.Context, presenceService PresenceService, messageService MessageService, userId string) {
+	presence, err := presenceService.GetPresence(ctx, userId)
+	if err != nil {
+		logger.GetLogger().Error("Failed to get presence", zap.String("user_id", userId), zap.Error(err))
+		return
+	}
+	contacts, err := presenceService.Contacts(ctx, userId)
+	if err != nil || len(contacts) == 0 {
+		return
+	}
+
+	content, err := json.Marshal(presence)
+	if err != nil {
+		return
+	}
+	event, err := json.Marshal(model.Event{
+		Type:      model.EventTypePresence,
+		EventID:   uuid.New().String(),
+		Timestamp: time.Now().UTC(),
+		Content:   content,
+	})
+	if err != nil {
+		return
+	}
+	if err := messageService.SendEphemeral(ctx, contacts, string(event)); err != nil {
+		logger.GetLogger().Error("Failed to notify presence", zap.String("user_id", userId), zap.Error(err))
+	}
+}
@@ -0,0 +1,20 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/config"
+)
+
+// RateLimiter limits how often and how many times at once clients act, across all chat-service replicas.
+type RateLimiter interface {
+	// Allow counts an action of the key against the limit, reporting whether it is allowed,
+	// or else how long to wait before the next action is.
+	Allow(ctx context.Context, key string, limit config.RateLimit) (bool, time.Duration, error)
+	// Acquire takes one of the max slots of the key, held until released, reporting whether one was left.
+	Acquire(ctx context.Context, key string, max int) (string, bool, error)
+	// Release frees a slot taken by Acquire.
+	Release(ctx context.Context, key string, slot string) error
+	Close() error
+}
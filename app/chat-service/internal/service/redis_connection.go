@@ -6,7 +6,9 @@ import (
 	"time"
 
 	"github.com/nsmsb/darda-chat/app/chat-service/internal/config"
+	"github.com/nsmsb/darda-chat/app/chat-service/pkg/logger"
 	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
 )
 
 type RedisConnection struct {
@@ -30,7 +32,7 @@ func (conn *RedisConnection) StartReading() {
 	// Ensure the reading goroutine is started only once per user
 	conn.startOnce.Do(func() {
 		go func() {
-			defer fmt.Println("Stopped reading from Redis for a connection")
+			defer logger.GetLogger().Info("Stopped reading from Redis for a connection", zap.Stringer("pubsub", conn.Conn))
 			redisChan := conn.Conn.Channel()
 			for msg := range redisChan {
 				conn.m.Lock()
@@ -40,14 +42,14 @@ func (conn *RedisConnection) StartReading() {
 						// Message sent successfully
 					case <-time.After(time.Millisecond * 500):
 						// Timeout ended, subscriber is not receiving messages or so slow
-						fmt.Println("Subscriber is not receiving messages, removing subscriber")
+						logger.GetLogger().Info("Subscriber is not receiving messages, removing subscriber", zap.Stringer("pubsub", conn.Conn), zap.Int("subscribers", conn.Count-1))
 						close(conn.Subscribers[ch])
 						delete(conn.Subscribers, ch)
 						conn.Count--
 						if conn.Count <= 0 {
 							// No more subscribers, exit reading loop
 							conn.m.Unlock()
-							fmt.Println("No more subscribers, exiting reading loop")
+							logger.GetLogger().Info("No more subscribers, exiting reading loop", zap.Stringer("pubsub", conn.Conn))
 							return
 						}
 					}
@@ -108,9 +110,10 @@ func (conn *RedisConnection) Close() error {
 	if err != nil {
 		return err
 	}
-	// Close all subscriber channels
-	for _, ch := range conn.Subscribers {
-		close(ch)
+	// Close all subscriber channels, those still subscribed won't close them again
+	for ch, biCh := range conn.Subscribers {
+		close(biCh)
+		delete(conn.Subscribers, ch)
 		conn.Count--
 	}
 	return nil
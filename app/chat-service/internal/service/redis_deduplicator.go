@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisDeduplicator keeps the ack of each recently sent message in Redis, so retries are detected whatever replica they reach.
+type RedisDeduplicator struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func NewRedisDeduplicator(client *redis.Client, ttl time.Duration) *RedisDeduplicator {
+	return &RedisDeduplicator{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+func dedupeKey(sender string, messageID string) string {
+	return fmt.Sprintf("dedupe:%s:%s", sender, messageID)
+}
+
+// Reserve stores the ack unless one is already stored for the message ID, in which case the stored ack is returned.
+func (d *RedisDeduplicator) Reserve(ctx context.Context, sender string, messageID string, ack model.Ack) (model.Ack, bool, error) {
+	strAck, err := json.Marshal(ack)
+	if err != nil {
+		return model.Ack{}, false, fmt.Errorf("failed to marshal ack: %w", err)
+	}
+
+	key := dedupeKey(sender, messageID)
+	reserved, err := d.client.SetNX(ctx, key, strAck, d.ttl).Result()
+	if err != nil {
+		return model.Ack{}, false, fmt.Errorf("failed to reserve message ID: %w", err)
+	}
+	if reserved {
+		return ack, false, nil
+	}
+
+	stored, err := d.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// Released or expired in between, the send can go on
+		return ack, false, nil
+	}
+	if err != nil {
+		return model.Ack{}, false, fmt.Errorf("failed to get reserved message ID: %w", err)
+	}
+	var storedAck model.Ack
+	if err := json.Unmarshal(stored, &storedAck); err != nil {
+		return model.Ack{}, false, fmt.Errorf("failed to unmarshal ack: %w", err)
+	}
+	return storedAck, true, nil
+}
+
+func (d *RedisDeduplicator) Release(ctx context.Context, sender string, messageID string) error {
+	if err := d.client.Del(ctx, dedupeKey(sender, messageID)).Err(); err != nil {
+		return fmt.Errorf("failed to release message ID: %w", err)
+	}
+	return nil
+}
@@ -6,36 +6,56 @@ import (
 	"sync"
 
 	"github.com/nsmsb/darda-chat/app/chat-service/internal/config"
+	"github.com/nsmsb/darda-chat/app/chat-service/pkg/logger"
 	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
 )
 
 type RedisMessageService struct {
 	client      *redis.Client
 	publisher   Publisher
+	presence    PresenceService
 	connections map[string]Connection
 	m           sync.Mutex
 }
 
-func NewRedisMessageService(client *redis.Client, publisher Publisher) *RedisMessageService {
-	return &RedisMessageService{
+func NewRedisMessageService(client *redis.Client, publisher Publisher, presence PresenceService) *RedisMessageService {
+	service := &RedisMessageService{
 		client:      client,
 		publisher:   publisher,
+		presence:    presence,
 		connections: make(map[string]Connection),
 	}
+	// Announcing the users left offline by crashed replicas
+	presence.OnExpired(func(ctx context.Context, userId string) {
+		notifyPresence(ctx, presence, service, userId)
+	})
+	return service
 }
 
-func (service *RedisMessageService) SendMessage(ctx context.Context, destination string, msg string) error {
+// SendMessage persists the message once through the message queue, then fans it out to every destination's channel.
+func (service *RedisMessageService) SendMessage(ctx context.Context, destinations []string, msg string) error {
 	config, _ := config.Get()
 	// Publishing message to message queue
 	err := service.publisher.Publish(ctx, msg, config.MsgQueue)
 	if err != nil {
 		return err
 	}
-	// Publishing message to Redis channel for real-time delivery
-	return service.client.Publish(ctx, fmt.Sprintf("user:%s", destination), msg).Err()
+	// Publishing message to Redis channels for real-time delivery
+	return service.SendEphemeral(ctx, destinations, msg)
 }
 
-func (service *RedisMessageService) SubscribeToMessages(ctx context.Context, channel string) (<-chan string, error) {
+// SendEphemeral publishes the message to every destination's channel in a single round trip, without persisting it.
+func (service *RedisMessageService) SendEphemeral(ctx context.Context, destinations []string, msg string) error {
+	pipe := service.client.Pipeline()
+	for _, destination := range destinations {
+		pipe.Publish(ctx, fmt.Sprintf("user:%s", destination), msg)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (service *RedisMessageService) SubscribeToMessages(ctx context.Context, channel string, device string) (<-chan string, error) {
 
 	// Subscribing to Redis messages channel
 	service.m.Lock()
@@ -49,32 +69,53 @@ func (service *RedisMessageService) SubscribeToMessages(ctx context.Context, cha
 	service.m.Unlock()
 	// Start reading messages from Redis since it's the first connection
 	conn.StartReading()
+	subscriber := conn.NewSubscriber()
+
+	// First socket of the user on this replica, updating presence
+	if !exists {
+		if cameOnline, err := service.presence.Connect(ctx, channel); err != nil {
+			logger.GetLogger().Error("Failed to update presence", zap.String("user_id", channel), zap.Error(err))
+		} else if cameOnline {
+			notifyPresence(ctx, service.presence, service, channel)
+		}
+	}
 
-	return conn.NewSubscriber(), nil
+	return subscriber, nil
 }
 
+// Acknowledge does nothing, messages published while the user is away are not delivered again.
+func (service *RedisMessageService) Acknowledge(channel string, msgCh <-chan string, msg string) {}
+
 func (service *RedisMessageService) UnsubscribeFromMessages(channel string, msgCh <-chan string) error {
 	service.m.Lock()
-	defer service.m.Unlock()
 	conn, exists := service.connections[channel]
 	if !exists {
+		service.m.Unlock()
 		return fmt.Errorf("no connection found for channel %s", channel)
 	}
-	// Remove the subscriber channel
+	// Remove the subscriber channel, it may already be gone if it was dropped for being too slow
 	err := conn.RemoveSubscriber(msgCh)
-	if err != nil {
-		return err
-	}
 
 	// If there are no more subscribers, remove the connection
-	if conn.SubscriberCount() == 0 {
-		fmt.Println("No more subscribers, closing connection for channel", channel)
-		err := conn.Close()
-		if err != nil {
-			return err
+	lastSubscriber := conn.SubscriberCount() <= 0
+	if lastSubscriber {
+		logger.GetLogger().Info("No more subscribers, closing connection", zap.String("user_id", channel))
+		if closeErr := conn.Close(); closeErr != nil {
+			err = closeErr
 		}
 		delete(service.connections, channel)
 	}
+	service.m.Unlock()
+
+	// Last socket of the user on this replica, updating presence
+	if lastSubscriber {
+		ctx := context.Background()
+		if wentOffline, presenceErr := service.presence.Disconnect(ctx, channel); presenceErr != nil {
+			logger.GetLogger().Error("Failed to update presence", zap.String("user_id", channel), zap.Error(presenceErr))
+		} else if wentOffline {
+			notifyPresence(ctx, service.presence, service, channel)
+		}
+	}
 
 	return err
 }
@@ -86,6 +127,10 @@ func (service *RedisMessageService) Close() error {
 	for _, conn := range service.connections {
 		conn.Close()
 	}
+	// Stop tracking presence of the users of this replica
+	if err := service.presence.Close(); err != nil {
+		return err
+	}
 	// Close the publisher
 	err := service.publisher.Close()
 	if err != nil {
@@ -94,5 +139,3 @@ func (service *RedisMessageService) Close() error {
 	// Close the Redis client and return any error
 	return service.client.Close()
 }
-
-// TODO: Switch to service-chat level routing to scale to millions of users
@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/model"
+	"github.com/nsmsb/darda-chat/app/chat-service/pkg/logger"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// presenceReplicasKey is the sorted set of the replicas with online users, scored by the expiry of their last heartbeat.
+const presenceReplicasKey = "presence_replicas"
+
+// RedisPresenceService stores presence in Redis so it is shared by all replicas.
+// Each online user has a sorted set of the replicas holding their sockets, scored by the expiry of the
+// replica's last heartbeat, so users of a crashed replica go offline once its entries expire.
+// Each replica also has the set of its users, which the other replicas sweep once it stops heartbeating,
+// to announce the users it left offline.
+type RedisPresenceService struct {
+	client            *redis.Client
+	instanceID        string
+	ttl               time.Duration
+	heartbeatInterval time.Duration
+	users             map[string]int // Connections of each user on this replica
+	expired           func(ctx context.Context, userId string)
+	m                 sync.Mutex
+	done              chan struct{}
+	closeOnce         sync.Once
+}
+
+func NewRedisPresenceService(client *redis.Client, instanceID string, ttl time.Duration, heartbeatInterval time.Duration) *RedisPresenceService {
+	service := &RedisPresenceService{
+		client:            client,
+		instanceID:        instanceID,
+		ttl:               ttl,
+		heartbeatInterval: heartbeatInterval,
+		users:             make(map[string]int),
+		done:              make(chan struct{}),
+	}
+	go service.heartbeat()
+	return service
+}
+
+func presenceKey(userId string) string {
+	return fmt.Sprintf("presence:%s", userId)
+}
+
+func lastSeenKey(userId string) string {
+	return fmt.Sprintf("last_seen:%s", userId)
+}
+
+func contactsKey(userId string) string {
+	return fmt.Sprintf("contacts:%s", userId)
+}
+
+func presenceUsersKey(instanceID string) string {
+	return fmt.Sprintf("presence_users:%s", instanceID)
+}
+
+// Connect registers this replica for the user and reports whether no other replica had the user online.
+func (service *RedisPresenceService) Connect(ctx context.Context, userId string) (bool, error) {
+	service.m.Lock()
+	service.users[userId]++
+	service.m.Unlock()
+
+	now := time.Now()
+	pipe := service.client.TxPipeline()
+	// Dropping replicas that stopped heartbeating before counting the online ones
+	pipe.ZRemRangeByScore(ctx, presenceKey(userId), "-inf", strconv.FormatInt(now.UnixMilli(), 10))
+	previous := pipe.ZCard(ctx, presenceKey(userId))
+	service.refresh(ctx, pipe, userId, now)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, fmt.Errorf("redis presence connect error: %w", err)
+	}
+	return previous.Val() == 0, nil
+}
+
+// Disconnect unregisters this replica for the user and reports whether no other replica still has the user online.
+func (service *RedisPresenceService) Disconnect(ctx context.Context, userId string) (bool, error) {
+	service.m.Lock()
+	service.users[userId]--
+	// The user reconnected on this replica meanwhile, presence is unchanged
+	if service.users[userId] > 0 {
+		service.m.Unlock()
+		return false, nil
+	}
+	delete(service.users, userId)
+	service.m.Unlock()
+
+	now := time.Now()
+	pipe := service.client.TxPipeline()
+	pipe.ZRem(ctx, presenceKey(userId), service.instanceID)
+	pipe.SRem(ctx, presenceUsersKey(service.instanceID), userId)
+	pipe.ZRemRangeByScore(ctx, presenceKey(userId), "-inf", strconv.FormatInt(now.UnixMilli(), 10))
+	remaining := pipe.ZCard(ctx, presenceKey(userId))
+	pipe.Set(ctx, lastSeenKey(userId), now.UnixMilli(), 0)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, fmt.Errorf("redis presence disconnect error: %w", err)
+	}
+	return remaining.Val() == 0, nil
+}
+
+// GetPresence returns whether any replica has a live heartbeat for the user, and when the user was last seen.
+func (service *RedisPresenceService) GetPresence(ctx context.Context, userId string) (*model.Presence, error) {
+	now := time.Now()
+	pipe := service.client.Pipeline()
+	online := pipe.ZCount(ctx, presenceKey(userId), strconv.FormatInt(now.UnixMilli(), 10), "+inf")
+	lastSeen := pipe.Get(ctx, lastSeenKey(userId))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("redis get presence error: %w", err)
+	}
+
+	presence := &model.Presence{
+		UserID: userId,
+		Status: model.PresenceOffline,
+	}
+	if lastSeenMillis, err := lastSeen.Int64(); err == nil {
+		presence.LastSeen = time.UnixMilli(lastSeenMillis).UTC()
+	}
+	if online.Val() > 0 {
+		presence.Status = model.PresenceOnline
+		presence.LastSeen = now.UTC()
+	}
+	return presence, nil
+}
+
+// AddContacts links the user and the contacts both ways.
+func (service *RedisPresenceService) AddContacts(ctx context.Context, userId string, contacts []string) error {
+	if len(contacts) == 0 {
+		return nil
+	}
+	pipe := service.client.Pipeline()
+	for _, contact := range contacts {
+		pipe.SAdd(ctx, contactsKey(userId), contact)
+		pipe.SAdd(ctx, contactsKey(contact), userId)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("redis add contacts error: %w", err)
+	}
+	return nil
+}
+
+// OnExpired sets the function called with each user left offline by a replica that stopped heartbeating.
+func (service *RedisPresenceService) OnExpired(notify func(ctx context.Context, userId string)) {
+	service.m.Lock()
+	defer service.m.Unlock()
+	service.expired = notify
+}
+
+func (service *RedisPresenceService) Contacts(ctx context.Context, userId string) ([]string, error) {
+	contacts, err := service.client.SMembers(ctx, contactsKey(userId)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis get contacts error: %w", err)
+	}
+	return contacts, nil
+}
+
+// Close stops heartbeating and removes this replica from the presence of its users.
+func (service *RedisPresenceService) Close() error {
+	service.closeOnce.Do(func() {
+		close(service.done)
+	})
+
+	service.m.Lock()
+	defer service.m.Unlock()
+	ctx := context.Background()
+	pipe := service.client.Pipeline()
+	for userId := range service.users {
+		pipe.ZRem(ctx, presenceKey(userId), service.instanceID)
+		pipe.Set(ctx, lastSeenKey(userId), time.Now().UnixMilli(), 0)
+	}
+	pipe.Del(ctx, presenceUsersKey(service.instanceID))
+	pipe.ZRem(ctx, presenceReplicasKey, service.instanceID)
+	clear(service.users)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// refresh queues the commands extending the presence of this replica for the user.
+func (service *RedisPresenceService) refresh(ctx context.Context, pipe redis.Pipeliner, userId string, now time.Time) {
+	expiry := float64(now.Add(service.ttl).UnixMilli())
+	pipe.ZAdd(ctx, presenceKey(userId), redis.Z{
+		Score:  expiry,
+		Member: service.instanceID,
+	})
+	pipe.Expire(ctx, presenceKey(userId), service.ttl)
+	pipe.Set(ctx, lastSeenKey(userId), now.UnixMilli(), 0)
+	pipe.SAdd(ctx, presenceUsersKey(service.instanceID), userId)
+	pipe.ZAdd(ctx, presenceReplicasKey, redis.Z{
+		Score:  expiry,
+		Member: service.instanceID,
+	})
+}
+
+// heartbeat periodically extends the presence of every user connected to this replica, and sweeps dead replicas.
+func (service *RedisPresenceService) heartbeat() {
+	ticker := time.NewTicker(service.heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-service.done:
+			return
+		case <-ticker.C:
+			service.m.Lock()
+			ctx := context.Background()
+			now := time.Now()
+			pipe := service.client.Pipeline()
+			for userId := range service.users {
+				service.refresh(ctx, pipe, userId, now)
+			}
+			service.m.Unlock()
+			if _, err := pipe.Exec(ctx); err != nil {
+				logger.GetLogger().Error("Failed to refresh presence heartbeats", zap.Error(err))
+			}
+			if err := service.removeDeadReplicas(ctx, now); err != nil {
+				logger.GetLogger().Error("Failed to remove presence of dead replicas", zap.Error(err))
+			}
+		}
+	}
+}
+
+// removeDeadReplicas removes the presence of the replicas that stopped heartbeating, and announces the users they
+// left without any live replica. Each dead replica is claimed by the first replica removing it, so users are
+// announced once even when several replicas sweep at once.
+func (service *RedisPresenceService) removeDeadReplicas(ctx context.Context, now time.Time) error {
+	dead, err := service.client.ZRangeByScore(ctx, presenceReplicasKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		return err
+	}
+	for _, replica := range dead {
+		claimed, err := service.client.ZRem(ctx, presenceReplicasKey, replica).Result()
+		if err != nil {
+			return err
+		}
+		if claimed == 0 {
+			continue
+		}
+		users, err := service.client.SMembers(ctx, presenceUsersKey(replica)).Result()
+		if err != nil {
+			return err
+		}
+
+		// Counting the live replicas left to each user, entries of other dead replicas being dropped too
+		pipe := service.client.Pipeline()
+		remaining := make([]*redis.IntCmd, len(users))
+		for i, userId := range users {
+			pipe.ZRem(ctx, presenceKey(userId), replica)
+			pipe.ZRemRangeByScore(ctx, presenceKey(userId), "-inf", strconv.FormatInt(now.UnixMilli(), 10))
+			remaining[i] = pipe.ZCard(ctx, presenceKey(userId))
+		}
+		pipe.Del(ctx, presenceUsersKey(replica))
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+
+		service.m.Lock()
+		expired := service.expired
+		service.m.Unlock()
+		if expired == nil {
+			continue
+		}
+		for i, userId := range users {
+			if remaining[i].Val() == 0 {
+				expired(ctx, userId)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/config"
+	"github.com/nsmsb/darda-chat/app/chat-service/pkg/logger"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	// slotTTL is the duration after which the slots held by a replica expire without heartbeat, when it crashed.
+	slotTTL = 30 * time.Second
+	// slotHeartbeatInterval is the interval between the heartbeats extending the slots held by the replica.
+	slotHeartbeatInterval = 10 * time.Second
+)
+
+// takeTokenScript takes a token from a bucket refilled continuously, holding up to the limit count.
+// It returns whether a token was taken, and otherwise the milliseconds to wait for the next one.
+var takeTokenScript = redis.NewScript(`
+local count = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'updated_at')
+local tokens = tonumber(bucket[1]) or count
+local updatedAt = tonumber(bucket[2]) or now
+tokens = math.min(count, tokens + math.max(0, now - updatedAt) * count / period)
+local allowed, wait = 0, 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  wait = math.ceil((1 - tokens) * period / count)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated_at', tostring(now))
+redis.call('PEXPIRE', KEYS[1], period)
+return {allowed, wait}
+`)
+
+// acquireSlotScript adds a slot to the set of slots of a key unless max slots are held, expired slots being left out.
+var acquireSlotScript = redis.NewScript(`
+local max = tonumber(ARGV[1])
+local now = tonumber(ARGV[3])
+local ttl = tonumber(ARGV[4])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+if redis.call('ZCARD', KEYS[1]) >= max then
+  return 0
+end
+redis.call('ZADD', KEYS[1], now + ttl, ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ttl)
+return 1
+`)
+
+// RedisRateLimiter keeps token buckets and slots in Redis so limits hold whatever replica clients reach.
+// Actions are limited with a token bucket per key, and concurrency with a sorted set of the slots of each key scored
+// by their expiry, extended by the replica holding them until they are released.
+type RedisRateLimiter struct {
+	client *redis.Client
+	slots  map[string]map[string]struct{} // Slots held by this replica, by key
+	m      sync.Mutex
+	done   chan struct{}
+	once   sync.Once
+}
+
+func NewRedisRateLimiter(client *redis.Client) *RedisRateLimiter {
+	limiter := &RedisRateLimiter{
+		client: client,
+		slots:  make(map[string]map[string]struct{}),
+		done:   make(chan struct{}),
+	}
+	go limiter.heartbeat()
+	return limiter
+}
+
+func rateLimitKey(key string) string {
+	return fmt.Sprintf("ratelimit:%s", key)
+}
+
+func slotsKey(key string) string {
+	return fmt.Sprintf("slots:%s", key)
+}
+
+// Allow takes a token from the bucket of the key, disabled limits allowing everything.
+func (limiter *RedisRateLimiter) Allow(ctx context.Context, key string, limit config.RateLimit) (bool, time.Duration, error) {
+	if limit.Count <= 0 {
+		return true, 0, nil
+	}
+	result, err := takeTokenScript.Run(ctx, limiter.client, []string{rateLimitKey(key)},
+		limit.Count, limit.Period.Milliseconds(), time.Now().UnixMilli()).Int64Slice()
+	if err != nil {
+		return false, 0, fmt.Errorf("redis rate limit error: %w", err)
+	}
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond, nil
+}
+
+// Acquire takes a slot of the key, no limit being set if max is 0.
+func (limiter *RedisRateLimiter) Acquire(ctx context.Context, key string, max int) (string, bool, error) {
+	if max <= 0 {
+		return "", true, nil
+	}
+	slot := uuid.New().String()
+	acquired, err := acquireSlotScript.Run(ctx, limiter.client, []string{slotsKey(key)},
+		max, slot, time.Now().UnixMilli(), slotTTL.Milliseconds()).Int()
+	if err != nil {
+		return "", false, fmt.Errorf("redis slot acquire error: %w", err)
+	}
+	if acquired == 0 {
+		return "", false, nil
+	}
+
+	limiter.m.Lock()
+	if limiter.slots[key] == nil {
+		limiter.slots[key] = make(map[string]struct{})
+	}
+	limiter.slots[key][slot] = struct{}{}
+	limiter.m.Unlock()
+	return slot, true, nil
+}
+
+func (limiter *RedisRateLimiter) Release(ctx context.Context, key string, slot string) error {
+	// Slots of unlimited keys are not stored
+	if slot == "" {
+		return nil
+	}
+	limiter.m.Lock()
+	delete(limiter.slots[key], slot)
+	if len(limiter.slots[key]) == 0 {
+		delete(limiter.slots, key)
+	}
+	limiter.m.Unlock()
+
+	if err := limiter.client.ZRem(ctx, slotsKey(key), slot).Err(); err != nil {
+		return fmt.Errorf("redis slot release error: %w", err)
+	}
+	return nil
+}
+
+// Close stops heartbeating and releases the slots held by this replica.
+func (limiter *RedisRateLimiter) Close() error {
+	limiter.once.Do(func() {
+		close(limiter.done)
+	})
+
+	limiter.m.Lock()
+	defer limiter.m.Unlock()
+	ctx := context.Background()
+	pipe := limiter.client.Pipeline()
+	for key, slots := range limiter.slots {
+		for slot := range slots {
+			pipe.ZRem(ctx, slotsKey(key), slot)
+		}
+	}
+	clear(limiter.slots)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// heartbeat periodically extends the slots held by this replica, those of crashed replicas expiring.
+func (limiter *RedisRateLimiter) heartbeat() {
+	ticker := time.NewTicker(slotHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-limiter.done:
+			return
+		case <-ticker.C:
+			limiter.m.Lock()
+			ctx := context.Background()
+			expiry := time.Now().Add(slotTTL)
+			pipe := limiter.client.Pipeline()
+			for key, slots := range limiter.slots {
+				for slot := range slots {
+					// Only extending slots still held, not adding back those released meanwhile
+					pipe.ZAddXX(ctx, slotsKey(key), redis.Z{Score: float64(expiry.UnixMilli()), Member: slot})
+				}
+				pipe.Expire(ctx, slotsKey(key), slotTTL)
+			}
+			limiter.m.Unlock()
+			if _, err := pipe.Exec(ctx); err != nil {
+				logger.GetLogger().Error("Failed to refresh rate limiter slots", zap.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nsmsb/darda-chat/app/chat-service/pkg/logger"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// routeReplicasKey is the sorted set of the replicas hosting users, scored by the expiry of their last heartbeat.
+const routeReplicasKey = "route_replicas"
+
+// RedisRouteRegistry stores routes in Redis so they are shared by all replicas.
+// Each user has a sorted set of the replicas holding their sockets, scored by the expiry of the replica's last
+// heartbeat, and each replica the set of its users, kept until the routes of the replica are cleaned up by the others
+// once it stops heartbeating.
+type RedisRouteRegistry struct {
+	client            *redis.Client
+	instanceID        string
+	ttl               time.Duration
+	heartbeatInterval time.Duration
+	users             map[string]int // Sockets of each user on this replica
+	m                 sync.Mutex
+	done              chan struct{}
+	closeOnce         sync.Once
+}
+
+func NewRedisRouteRegistry(client *redis.Client, instanceID string, ttl time.Duration, heartbeatInterval time.Duration) *RedisRouteRegistry {
+	registry := &RedisRouteRegistry{
+		client:            client,
+		instanceID:        instanceID,
+		ttl:               ttl,
+		heartbeatInterval: heartbeatInterval,
+		users:             make(map[string]int),
+		done:              make(chan struct{}),
+	}
+	go registry.heartbeat()
+	return registry
+}
+
+func routeKey(userId string) string {
+	return fmt.Sprintf("route:%s", userId)
+}
+
+func routeUsersKey(instanceID string) string {
+	return fmt.Sprintf("route_users:%s", instanceID)
+}
+
+func (registry *RedisRouteRegistry) Register(ctx context.Context, userId string) error {
+	registry.m.Lock()
+	registry.users[userId]++
+	registry.m.Unlock()
+
+	pipe := registry.client.TxPipeline()
+	registry.refresh(ctx, pipe, userId, time.Now())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("redis route register error: %w", err)
+	}
+	return nil
+}
+
+func (registry *RedisRouteRegistry) Unregister(ctx context.Context, userId string) error {
+	registry.m.Lock()
+	registry.users[userId]--
+	// The user reconnected on this replica meanwhile, the route is kept
+	if registry.users[userId] > 0 {
+		registry.m.Unlock()
+		return nil
+	}
+	delete(registry.users, userId)
+	registry.m.Unlock()
+
+	pipe := registry.client.TxPipeline()
+	pipe.ZRem(ctx, routeKey(userId), registry.instanceID)
+	pipe.SRem(ctx, routeUsersKey(registry.instanceID), userId)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("redis route unregister error: %w", err)
+	}
+	return nil
+}
+
+// Lookup reads the routes of all users in a single round trip, leaving out replicas that stopped heartbeating.
+func (registry *RedisRouteRegistry) Lookup(ctx context.Context, userIds []string) (map[string][]string, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	pipe := registry.client.Pipeline()
+	replicas := make([]*redis.StringSliceCmd, len(userIds))
+	for i, userId := range userIds {
+		replicas[i] = pipe.ZRangeByScore(ctx, routeKey(userId), &redis.ZRangeBy{Min: now, Max: "+inf"})
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("redis route lookup error: %w", err)
+	}
+
+	routes := make(map[string][]string)
+	for i, userId := range userIds {
+		for _, replica := range replicas[i].Val() {
+			routes[replica] = append(routes[replica], userId)
+		}
+	}
+	return routes, nil
+}
+
+// Close stops heartbeating and removes the routes to this replica.
+func (registry *RedisRouteRegistry) Close() error {
+	registry.closeOnce.Do(func() {
+		close(registry.done)
+	})
+
+	registry.m.Lock()
+	defer registry.m.Unlock()
+	ctx := context.Background()
+	pipe := registry.client.Pipeline()
+	for userId := range registry.users {
+		pipe.ZRem(ctx, routeKey(userId), registry.instanceID)
+	}
+	pipe.Del(ctx, routeUsersKey(registry.instanceID))
+	pipe.ZRem(ctx, routeReplicasKey, registry.instanceID)
+	clear(registry.users)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// refresh queues the commands extending the route of the user to this replica.
+func (registry *RedisRouteRegistry) refresh(ctx context.Context, pipe redis.Pipeliner, userId string, now time.Time) {
+	expiry := float64(now.Add(registry.ttl).UnixMilli())
+	pipe.ZAdd(ctx, routeKey(userId), redis.Z{
+		Score:  expiry,
+		Member: registry.instanceID,
+	})
+	pipe.Expire(ctx, routeKey(userId), registry.ttl)
+	pipe.SAdd(ctx, routeUsersKey(registry.instanceID), userId)
+	pipe.ZAdd(ctx, routeReplicasKey, redis.Z{
+		Score:  expiry,
+		Member: registry.instanceID,
+	})
+}
+
+// heartbeat periodically extends the routes of every user connected to this replica, and cleans up those of dead replicas.
+func (registry *RedisRouteRegistry) heartbeat() {
+	ticker := time.NewTicker(registry.heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-registry.done:
+			return
+		case <-ticker.C:
+			registry.m.Lock()
+			ctx := context.Background()
+			now := time.Now()
+			pipe := registry.client.Pipeline()
+			for userId := range registry.users {
+				registry.refresh(ctx, pipe, userId, now)
+			}
+			registry.m.Unlock()
+			if _, err := pipe.Exec(ctx); err != nil {
+				logger.GetLogger().Error("Failed to refresh route heartbeats", zap.Error(err))
+			}
+			if err := registry.removeDeadReplicas(ctx, now); err != nil {
+				logger.GetLogger().Error("Failed to remove routes of dead replicas", zap.Error(err))
+			}
+		}
+	}
+}
+
+// removeDeadReplicas removes the routes of the replicas that stopped heartbeating, several replicas may do it at once.
+func (registry *RedisRouteRegistry) removeDeadReplicas(ctx context.Context, now time.Time) error {
+	dead, err := registry.client.ZRangeByScore(ctx, routeReplicasKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		return err
+	}
+	for _, replica := range dead {
+		users, err := registry.client.SMembers(ctx, routeUsersKey(replica)).Result()
+		if err != nil {
+			return err
+		}
+		pipe := registry.client.Pipeline()
+		for _, userId := range users {
+			pipe.ZRem(ctx, routeKey(userId), replica)
+		}
+		pipe.Del(ctx, routeUsersKey(replica))
+		pipe.ZRem(ctx, routeReplicasKey, replica)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,381 @@
+package service
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeRedis serves the sorted set and set commands used by the route registry, keys never expiring.
+type fakeRedis struct {
+	zsets map[string]map[string]float64
+	sets  map[string]map[string]bool
+	m     sync.Mutex
+}
+
+// newFakeRedis starts a fake Redis server and returns a client connected to it.
+func newFakeRedis(t *testing.T) (*fakeRedis, *redis.Client) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeRedis{
+		zsets: make(map[string]map[string]float64),
+		sets:  make(map[string]map[string]bool),
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	// RESP2 without client identity, so the connection handshake is a single HELLO the server refuses
+	client := redis.NewClient(&redis.Options{Addr: listener.Addr().String(), Protocol: 2, DisableIdentity: true})
+	t.Cleanup(func() {
+		client.Close()
+		listener.Close()
+	})
+	return server, client
+}
+
+func (server *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	var queued [][]string
+	inTx := false
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		var reply string
+		switch name := strings.ToUpper(args[0]); {
+		case name == "MULTI":
+			inTx, queued = true, nil
+			reply = "+OK\r\n"
+		case name == "EXEC":
+			replies := make([]string, 0, len(queued))
+			server.m.Lock()
+			for _, command := range queued {
+				replies = append(replies, server.exec(command))
+			}
+			server.m.Unlock()
+			inTx = false
+			reply = fmt.Sprintf("*%d\r\n%s", len(replies), strings.Join(replies, ""))
+		case inTx:
+			queued = append(queued, args)
+			reply = "+QUEUED\r\n"
+		default:
+			server.m.Lock()
+			reply = server.exec(args)
+			server.m.Unlock()
+		}
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+// readCommand reads a command sent as an array of bulk strings.
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
+
+// exec runs a command and returns its encoded reply.
+func (server *fakeRedis) exec(args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "ZADD":
+		zset := server.zsets[args[1]]
+		if zset == nil {
+			zset = make(map[string]float64)
+			server.zsets[args[1]] = zset
+		}
+		added := 0
+		for i := 2; i+1 < len(args); i += 2 {
+			score, _ := strconv.ParseFloat(args[i], 64)
+			if _, exists := zset[args[i+1]]; !exists {
+				added++
+			}
+			zset[args[i+1]] = score
+		}
+		return integerReply(added)
+	case "ZREM":
+		removed := 0
+		for _, member := range args[2:] {
+			if _, exists := server.zsets[args[1]][member]; exists {
+				delete(server.zsets[args[1]], member)
+				removed++
+			}
+		}
+		return integerReply(removed)
+	case "ZRANGEBYSCORE":
+		min, max := parseScore(args[2]), parseScore(args[3])
+		zset := server.zsets[args[1]]
+		members := slices.Collect(maps.Keys(zset))
+		members = slices.DeleteFunc(members, func(member string) bool {
+			return zset[member] < min || zset[member] > max
+		})
+		slices.SortFunc(members, func(a string, b string) int {
+			return cmp.Or(cmp.Compare(zset[a], zset[b]), strings.Compare(a, b))
+		})
+		return arrayReply(members)
+	case "SADD":
+		set := server.sets[args[1]]
+		if set == nil {
+			set = make(map[string]bool)
+			server.sets[args[1]] = set
+		}
+		added := 0
+		for _, member := range args[2:] {
+			if !set[member] {
+				added++
+			}
+			set[member] = true
+		}
+		return integerReply(added)
+	case "SREM":
+		removed := 0
+		for _, member := range args[2:] {
+			if server.sets[args[1]][member] {
+				delete(server.sets[args[1]], member)
+				removed++
+			}
+		}
+		return integerReply(removed)
+	case "SMEMBERS":
+		members := slices.Sorted(maps.Keys(server.sets[args[1]]))
+		return arrayReply(members)
+	case "DEL":
+		deleted := 0
+		for _, key := range args[1:] {
+			if server.zsets[key] != nil || server.sets[key] != nil {
+				deleted++
+			}
+			delete(server.zsets, key)
+			delete(server.sets, key)
+		}
+		return integerReply(deleted)
+	case "EXPIRE":
+		return integerReply(1)
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+	}
+}
+
+func parseScore(value string) float64 {
+	switch value {
+	case "-inf":
+		return math.Inf(-1)
+	case "+inf":
+		return math.Inf(1)
+	}
+	score, _ := strconv.ParseFloat(value, 64)
+	return score
+}
+
+func integerReply(n int) string {
+	return fmt.Sprintf(":%d\r\n", n)
+}
+
+func arrayReply(values []string) string {
+	var reply strings.Builder
+	fmt.Fprintf(&reply, "*%d\r\n", len(values))
+	for _, value := range values {
+		fmt.Fprintf(&reply, "$%d\r\n%s\r\n", len(value), value)
+	}
+	return reply.String()
+}
+
+// routeStep is an action of a replica on the route registry.
+type routeStep struct {
+	replica string
+	action  string // "register", "unregister", "close" or "wait" for the route TTL to pass
+	userId  string
+}
+
+func TestRedisRouteRegistry(t *testing.T) {
+	// Routes expire by the score of their replica, the keys themselves being kept for at least a second
+	const ttl = time.Second
+	tests := []struct {
+		name       string
+		steps      []routeStep
+		lookup     []string
+		wantRoutes map[string][]string
+	}{
+		{
+			name:       "registered user",
+			steps:      []routeStep{{replica: "r1", action: "register", userId: "u1"}},
+			lookup:     []string{"u1"},
+			wantRoutes: map[string][]string{"r1": {"u1"}},
+		},
+		{
+			name: "users on several replicas",
+			steps: []routeStep{
+				{replica: "r1", action: "register", userId: "u1"},
+				{replica: "r2", action: "register", userId: "u1"},
+				{replica: "r2", action: "register", userId: "u2"},
+			},
+			lookup:     []string{"u1", "u2", "u3"},
+			wantRoutes: map[string][]string{"r1": {"u1"}, "r2": {"u1", "u2"}},
+		},
+		{
+			name: "user without sockets left",
+			steps: []routeStep{
+				{replica: "r1", action: "register", userId: "u1"},
+				{replica: "r1", action: "unregister", userId: "u1"},
+			},
+			lookup:     []string{"u1"},
+			wantRoutes: map[string][]string{},
+		},
+		{
+			name: "user with another socket on the replica",
+			steps: []routeStep{
+				{replica: "r1", action: "register", userId: "u1"},
+				{replica: "r1", action: "register", userId: "u1"},
+				{replica: "r1", action: "unregister", userId: "u1"},
+			},
+			lookup:     []string{"u1"},
+			wantRoutes: map[string][]string{"r1": {"u1"}},
+		},
+		{
+			name: "closed replica",
+			steps: []routeStep{
+				{replica: "r1", action: "register", userId: "u1"},
+				{replica: "r2", action: "register", userId: "u1"},
+				{replica: "r1", action: "close"},
+			},
+			lookup:     []string{"u1"},
+			wantRoutes: map[string][]string{"r2": {"u1"}},
+		},
+		{
+			name: "replica that stopped heartbeating",
+			steps: []routeStep{
+				{replica: "r1", action: "register", userId: "u1"},
+				{action: "wait"},
+				{replica: "r2", action: "register", userId: "u1"},
+			},
+			lookup:     []string{"u1"},
+			wantRoutes: map[string][]string{"r2": {"u1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newFakeRedis(t)
+			ctx := context.Background()
+			// Heartbeats are left out, replicas stop heartbeating once their routes expire
+			registries := make(map[string]*RedisRouteRegistry)
+			registry := func(replica string) *RedisRouteRegistry {
+				if registries[replica] == nil {
+					registries[replica] = NewRedisRouteRegistry(client, replica, ttl, time.Hour)
+					t.Cleanup(func() { registries[replica].Close() })
+				}
+				return registries[replica]
+			}
+
+			for _, step := range tt.steps {
+				var err error
+				switch step.action {
+				case "register":
+					err = registry(step.replica).Register(ctx, step.userId)
+				case "unregister":
+					err = registry(step.replica).Unregister(ctx, step.userId)
+				case "close":
+					err = registry(step.replica).Close()
+				case "wait":
+					time.Sleep(ttl + 100*time.Millisecond)
+				}
+				if err != nil {
+					t.Fatalf("%s %s on %s error = %v", step.action, step.userId, step.replica, err)
+				}
+			}
+
+			routes, err := registry("lookup").Lookup(ctx, tt.lookup)
+			if err != nil {
+				t.Fatalf("Lookup() error = %v", err)
+			}
+			if !maps.EqualFunc(routes, tt.wantRoutes, slices.Equal) {
+				t.Fatalf("Lookup() = %v, want %v", routes, tt.wantRoutes)
+			}
+		})
+	}
+}
+
+func TestRedisRouteRegistryRemoveDeadReplicas(t *testing.T) {
+	server, client := newFakeRedis(t)
+	ctx := context.Background()
+	dead := NewRedisRouteRegistry(client, "dead", time.Second, time.Hour)
+	live := NewRedisRouteRegistry(client, "live", time.Hour, time.Hour)
+	t.Cleanup(func() {
+		dead.Close()
+		live.Close()
+	})
+	for _, userId := range []string{"u1", "u2"} {
+		if err := dead.Register(ctx, userId); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := live.Register(ctx, "u1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := live.removeDeadReplicas(ctx, time.Now().Add(2*time.Second)); err != nil {
+		t.Fatalf("removeDeadReplicas() error = %v", err)
+	}
+
+	server.m.Lock()
+	defer server.m.Unlock()
+	wantRoutes := map[string][]string{
+		routeKey("u1"):   {"live"},
+		routeKey("u2"):   {},
+		routeReplicasKey: {"live"},
+	}
+	for key, want := range wantRoutes {
+		if got := slices.Sorted(maps.Keys(server.zsets[key])); !slices.Equal(got, want) && len(got)+len(want) > 0 {
+			t.Errorf("%s = %v, want %v", key, got, want)
+		}
+	}
+	if users := server.sets[routeUsersKey("dead")]; users != nil {
+		t.Errorf("%s = %v, want it removed", routeUsersKey("dead"), users)
+	}
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/config"
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/model"
+	"github.com/nsmsb/darda-chat/app/chat-service/pkg/logger"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// RedisRoutedMessageService delivers events through one Redis channel per replica instead of one per user.
+// Events are only published to the replicas the route registry maps their destinations to,
+// and the reader of the replica's channel hands them to the local connections of their users.
+type RedisRoutedMessageService struct {
+	client      *redis.Client
+	publisher   Publisher
+	presence    PresenceService
+	routes      RouteRegistry
+	pubsub      *redis.PubSub
+	connections map[string]*LocalConnection
+	m           sync.Mutex
+}
+
+// NewRedisRoutedMessageService subscribes to the channel of this replica and starts reading it.
+func NewRedisRoutedMessageService(ctx context.Context, client *redis.Client, publisher Publisher, presence PresenceService, routes RouteRegistry, instanceID string) (*RedisRoutedMessageService, error) {
+	// Waiting for the subscription, events routed to this replica before it would be lost
+	pubsub := client.Subscribe(ctx, replicaChannel(instanceID))
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("redis subscribe replica channel error: %w", err)
+	}
+
+	service := &RedisRoutedMessageService{
+		client:      client,
+		publisher:   publisher,
+		presence:    presence,
+		routes:      routes,
+		pubsub:      pubsub,
+		connections: make(map[string]*LocalConnection),
+	}
+	// Announcing the users left offline by crashed replicas
+	presence.OnExpired(func(ctx context.Context, userId string) {
+		notifyPresence(ctx, presence, service, userId)
+	})
+	go service.readRoutedEvents()
+	return service, nil
+}
+
+func replicaChannel(instanceID string) string {
+	return fmt.Sprintf("replica:%s", instanceID)
+}
+
+// SendMessage persists the message once through the message queue, then routes it to the replicas of its destinations.
+func (service *RedisRoutedMessageService) SendMessage(ctx context.Context, destinations []string, msg string) error {
+	config, _ := config.Get()
+	// Publishing message to message queue
+	err := service.publisher.Publish(ctx, msg, config.MsgQueue)
+	if err != nil {
+		return err
+	}
+	// Publishing message to the replicas of the destinations for real-time delivery
+	return service.SendEphemeral(ctx, destinations, msg)
+}
+
+// SendEphemeral publishes the message once to each replica hosting some of the destinations, in a single round trip.
+// Destinations without sockets are not routed anywhere.
+func (service *RedisRoutedMessageService) SendEphemeral(ctx context.Context, destinations []string, msg string) error {
+	routes, err := service.routes.Lookup(ctx, destinations)
+	if err != nil {
+		return err
+	}
+	if len(routes) == 0 {
+		return nil
+	}
+
+	pipe := service.client.Pipeline()
+	for replica, users := range routes {
+		routed, err := json.Marshal(model.RoutedEvent{
+			Users: users,
+			Event: json.RawMessage(msg),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal routed event: %w", err)
+		}
+		pipe.Publish(ctx, replicaChannel(replica), routed)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (service *RedisRoutedMessageService) SubscribeToMessages(ctx context.Context, channel string, device string) (<-chan string, error) {
+	service.m.Lock()
+	conn, exists := service.connections[channel]
+	// Creating the local connection if it doesn't exist
+	if !exists {
+		conn = NewLocalConnection()
+		service.connections[channel] = conn
+	}
+	subscriber := conn.NewSubscriber()
+	service.m.Unlock()
+
+	// Routing the user's events to this replica, for every socket so the route outlives a racing unsubscribe.
+	// A failed registration is retried by the next heartbeat of the registry.
+	if err := service.routes.Register(ctx, channel); err != nil {
+		logger.GetLogger().Error("Failed to update route", zap.String("user_id", channel), zap.Error(err))
+	}
+
+	// First socket of the user on this replica, updating presence
+	if !exists {
+		if cameOnline, err := service.presence.Connect(ctx, channel); err != nil {
+			logger.GetLogger().Error("Failed to update presence", zap.String("user_id", channel), zap.Error(err))
+		} else if cameOnline {
+			notifyPresence(ctx, service.presence, service, channel)
+		}
+	}
+
+	return subscriber, nil
+}
+
+// Acknowledge does nothing, messages published while the user is away are not delivered again.
+func (service *RedisRoutedMessageService) Acknowledge(channel string, msgCh <-chan string, msg string) {
+}
+
+func (service *RedisRoutedMessageService) UnsubscribeFromMessages(channel string, msgCh <-chan string) error {
+	service.m.Lock()
+	conn, exists := service.connections[channel]
+	if !exists {
+		service.m.Unlock()
+		return fmt.Errorf("no connection found for channel %s", channel)
+	}
+	// Remove the subscriber channel, it may already be gone if it was dropped for being too slow
+	err := conn.RemoveSubscriber(msgCh)
+
+	// If there are no more subscribers, remove the connection
+	lastSubscriber := conn.SubscriberCount() <= 0
+	if lastSubscriber {
+		delete(service.connections, channel)
+	}
+	service.m.Unlock()
+
+	ctx := context.Background()
+	if routeErr := service.routes.Unregister(ctx, channel); routeErr != nil {
+		logger.GetLogger().Error("Failed to update route", zap.String("user_id", channel), zap.Error(routeErr))
+	}
+
+	// Last socket of the user on this replica, updating presence
+	if lastSubscriber {
+		if wentOffline, presenceErr := service.presence.Disconnect(ctx, channel); presenceErr != nil {
+			logger.GetLogger().Error("Failed to update presence", zap.String("user_id", channel), zap.Error(presenceErr))
+		} else if wentOffline {
+			notifyPresence(ctx, service.presence, service, channel)
+		}
+	}
+
+	return err
+}
+
+// readRoutedEvents hands the events published to this replica to the connections of their users, until the channel is closed.
+func (service *RedisRoutedMessageService) readRoutedEvents() {
+	defer logger.GetLogger().Info("Stopped reading routed events from Redis")
+	for msg := range service.pubsub.Channel() {
+		var routed model.RoutedEvent
+		if err := json.Unmarshal([]byte(msg.Payload), &routed); err != nil {
+			logger.GetLogger().Error("Failed to unmarshal routed event", zap.Error(err))
+			continue
+		}
+		event := string(routed.Event)
+
+		service.m.Lock()
+		for _, userId := range routed.Users {
+			// The user may have left this replica since the lookup
+			if conn, exists := service.connections[userId]; exists {
+				conn.Deliver(event)
+			}
+		}
+		service.m.Unlock()
+	}
+}
+
+func (service *RedisRoutedMessageService) Close() error {
+	// Stop reading the replica's channel
+	if err := service.pubsub.Close(); err != nil {
+		return err
+	}
+	service.m.Lock()
+	defer service.m.Unlock()
+	// Close all connections
+	for _, conn := range service.connections {
+		conn.Close()
+	}
+	// Stop routing users to this replica, and tracking their presence
+	if err := service.routes.Close(); err != nil {
+		return err
+	}
+	if err := service.presence.Close(); err != nil {
+		return err
+	}
+	// Close the publisher
+	err := service.publisher.Close()
+	if err != nil {
+		return err
+	}
+	// Close the Redis client and return any error
+	return service.client.Close()
+}
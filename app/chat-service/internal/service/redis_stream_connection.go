@@ -0,0 +1,200 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// streamSubscriber reads the stream of a user from its own offset, so each device gets every entry once.
+type streamSubscriber struct {
+	ch        chan string
+	offsetKey string         // Key the offset is saved to, empty for subscribers without device
+	lastID    string         // ID of the last entry sent to the subscriber
+	pending   []pendingEntry // Entries sent to the subscriber, in order, from the first not yet written to the client
+	offset    string         // ID of the last entry written to the client, entries before it included
+}
+
+// pendingEntry is an entry sent to a subscriber, waiting to be acknowledged.
+type pendingEntry struct {
+	id    string
+	event string
+	acked bool
+}
+
+// RedisStreamConnection distributes the entries of the Redis stream of a user to the user's subscribers on this replica.
+// Entries are read by the stream reader of the replica, from the offset of the subscriber furthest behind with room for more.
+type RedisStreamConnection struct {
+	Stream      string
+	subscribers map[<-chan string]*streamSubscriber
+	m           sync.Mutex
+}
+
+func NewRedisStreamConnection(stream string) *RedisStreamConnection {
+	return &RedisStreamConnection{
+		Stream:      stream,
+		subscribers: make(map[<-chan string]*streamSubscriber),
+	}
+}
+
+// NewSubscriber adds a subscriber reading the stream after lastID, its offset being saved to offsetKey if not empty.
+func (conn *RedisStreamConnection) NewSubscriber(offsetKey string, lastID string) <-chan string {
+	config, _ := config.Get()
+	ch := make(chan string, config.SubsChanBufferSize)
+
+	conn.m.Lock()
+	defer conn.m.Unlock()
+	conn.subscribers[ch] = &streamSubscriber{ch: ch, offsetKey: offsetKey, lastID: lastID, offset: lastID}
+	return ch
+}
+
+// RemoveSubscriber removes a subscriber channel from the connection, returning its offset key and offset to save.
+func (conn *RedisStreamConnection) RemoveSubscriber(ch <-chan string) (string, string, error) {
+	conn.m.Lock()
+	defer conn.m.Unlock()
+	subscriber, exists := conn.subscribers[ch]
+	if !exists {
+		return "", "", fmt.Errorf("subscriber channel not found")
+	}
+	close(subscriber.ch)
+	delete(conn.subscribers, ch)
+	return subscriber.offsetKey, subscriber.offset, nil
+}
+
+// SubscriberCount returns the number of active subscribers
+func (conn *RedisStreamConnection) SubscriberCount() int {
+	conn.m.Lock()
+	defer conn.m.Unlock()
+	return len(conn.subscribers)
+}
+
+// ReadFrom returns the ID the stream must be read after for the subscribers with room for more entries,
+// empty if every subscriber's buffer is full, and whether some subscribers are paused for having a full buffer.
+func (conn *RedisStreamConnection) ReadFrom() (string, bool) {
+	conn.m.Lock()
+	defer conn.m.Unlock()
+	var from string
+	paused := false
+	for _, subscriber := range conn.subscribers {
+		if len(subscriber.ch) == cap(subscriber.ch) {
+			paused = true
+			continue
+		}
+		if from == "" || compareStreamIDs(subscriber.lastID, from) < 0 {
+			from = subscriber.lastID
+		}
+	}
+	return from, paused
+}
+
+// Deliver sends each subscriber the entries it didn't receive yet, in order, without waiting for slow subscribers.
+// A subscriber whose buffer is full stops at the last entry it received, and gets the next ones from there once it makes room.
+// Offsets only move once the entries are acknowledged, entries without event being acknowledged as they are read.
+// The offsets of the subscribers with a device that moved are added to offsets, by key.
+func (conn *RedisStreamConnection) Deliver(entries []redis.XMessage, offsets map[string]string) {
+	conn.m.Lock()
+	defer conn.m.Unlock()
+	for _, subscriber := range conn.subscribers {
+	entries:
+		for _, entry := range entries {
+			if compareStreamIDs(entry.ID, subscriber.lastID) <= 0 {
+				continue
+			}
+			event, ok := entry.Values["event"].(string)
+			if ok {
+				select {
+				case subscriber.ch <- event:
+				default:
+					break entries
+				}
+			}
+			subscriber.lastID = entry.ID
+			if subscriber.offsetKey != "" {
+				subscriber.pending = append(subscriber.pending, pendingEntry{id: entry.ID, event: event, acked: !ok})
+			}
+		}
+		if subscriber.commit() {
+			offsets[subscriber.offsetKey] = subscriber.offset
+		}
+	}
+}
+
+// Ack acknowledges the first pending event of the subscriber equal to event, once it is written to the client,
+// and returns the subscriber's offset key and offset if the offset moved.
+func (conn *RedisStreamConnection) Ack(ch <-chan string, event string) (string, string, bool) {
+	conn.m.Lock()
+	defer conn.m.Unlock()
+	subscriber, exists := conn.subscribers[ch]
+	if !exists {
+		return "", "", false
+	}
+	for i := range subscriber.pending {
+		if !subscriber.pending[i].acked && subscriber.pending[i].event == event {
+			subscriber.pending[i].acked = true
+			break
+		}
+	}
+	if !subscriber.commit() {
+		return "", "", false
+	}
+	return subscriber.offsetKey, subscriber.offset, true
+}
+
+// commit moves the offset of the subscriber past the acknowledged entries following it, and reports whether it moved.
+func (subscriber *streamSubscriber) commit() bool {
+	n := 0
+	for n < len(subscriber.pending) && subscriber.pending[n].acked {
+		n++
+	}
+	if n == 0 {
+		return false
+	}
+	subscriber.offset = subscriber.pending[n-1].id
+	subscriber.pending = subscriber.pending[n:]
+	return true
+}
+
+// Close closes all subscriber channels
+func (conn *RedisStreamConnection) Close() error {
+	conn.m.Lock()
+	defer conn.m.Unlock()
+	for ch, subscriber := range conn.subscribers {
+		close(subscriber.ch)
+		delete(conn.subscribers, ch)
+	}
+	return nil
+}
+
+// compareStreamIDs compares two Redis stream entry IDs, "{milliseconds}-{sequence}", in the order of the stream.
+func compareStreamIDs(a string, b string) int {
+	aMs, aSeq := parseStreamID(a)
+	bMs, bSeq := parseStreamID(b)
+	switch {
+	case aMs != bMs:
+		return compareUint(aMs, bMs)
+	default:
+		return compareUint(aSeq, bSeq)
+	}
+}
+
+func parseStreamID(id string) (uint64, uint64) {
+	ms, seq, _ := strings.Cut(id, "-")
+	msValue, _ := strconv.ParseUint(ms, 10, 64)
+	seqValue, _ := strconv.ParseUint(seq, 10, 64)
+	return msValue, seqValue
+}
+
+func compareUint(a uint64, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCompareStreamIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want int
+	}{
+		{name: "equal", a: "1700000000000-0", b: "1700000000000-0", want: 0},
+		{name: "older milliseconds", a: "1700000000000-5", b: "1700000000001-0", want: -1},
+		{name: "newer milliseconds", a: "1700000000001-0", b: "1700000000000-5", want: 1},
+		{name: "older sequence", a: "1700000000000-1", b: "1700000000000-2", want: -1},
+		{name: "newer sequence", a: "1700000000000-2", b: "1700000000000-1", want: 1},
+		{name: "numeric rather than lexical order", a: "999-0", b: "1000-0", want: -1},
+		{name: "sequence numeric rather than lexical order", a: "1000-9", b: "1000-10", want: -1},
+		{name: "smallest ID", a: "0-0", b: "1-0", want: -1},
+		{name: "missing sequence", a: "1000", b: "1000-0", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compareStreamIDs(tt.a, tt.b); got != tt.want {
+				t.Fatalf("compareStreamIDs(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedisStreamConnectionAck(t *testing.T) {
+	entries := []redis.XMessage{
+		{ID: "1-0", Values: map[string]any{"event": "a"}},
+		{ID: "2-0", Values: map[string]any{"event": "b"}},
+		{ID: "3-0", Values: map[string]any{"wake": "1"}},
+		{ID: "4-0", Values: map[string]any{"event": "c"}},
+	}
+	tests := []struct {
+		name       string
+		acks       []string
+		wantOffset string
+	}{
+		{name: "nothing written", acks: nil, wantOffset: "0-0"},
+		{name: "first written", acks: []string{"a"}, wantOffset: "1-0"},
+		{name: "later written first", acks: []string{"b"}, wantOffset: "0-0"},
+		{name: "entries without event skipped", acks: []string{"a", "b"}, wantOffset: "3-0"},
+		{name: "all written out of order", acks: []string{"c", "b", "a"}, wantOffset: "4-0"},
+		{name: "unknown event", acks: []string{"x"}, wantOffset: "0-0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := NewRedisStreamConnection(userStream("u1"))
+			ch := make(chan string, len(entries))
+			conn.subscribers[ch] = &streamSubscriber{ch: ch, offsetKey: streamOffsetKey("u1", "d1"), lastID: "0-0", offset: "0-0"}
+
+			offsets := make(map[string]string)
+			conn.Deliver(entries, offsets)
+			if len(offsets) != 0 {
+				t.Fatalf("Deliver moved offsets %v before any event was written", offsets)
+			}
+			for _, event := range tt.acks {
+				conn.Ack(ch, event)
+			}
+			if _, offset, err := conn.RemoveSubscriber(ch); err != nil || offset != tt.wantOffset {
+				t.Fatalf("offset = %q, %v, want %q", offset, err, tt.wantOffset)
+			}
+		})
+	}
+}
@@ -0,0 +1,365 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nsmsb/darda-chat/app/chat-service/internal/config"
+	"github.com/nsmsb/darda-chat/app/chat-service/pkg/logger"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// streamReadBlock is the longest a read of the streams waits for new entries before checking for shutdown.
+const streamReadBlock = 5 * time.Second
+
+// streamReadCount is the maximum number of entries read from each stream at once.
+const streamReadCount = 100
+
+// streamPausedReadBlock is the longest a read waits while subscribers are paused, so they resume soon after making room.
+const streamPausedReadBlock = 100 * time.Millisecond
+
+// streamOffsetSaveInterval is the interval at which the offsets of the events written to the clients are saved.
+const streamOffsetSaveInterval = time.Second
+
+// RedisStreamMessageService delivers events through a capped Redis stream per user instead of a pub/sub channel,
+// so events published while the user is briefly disconnected, or while its replica restarts, are delivered on reconnect.
+// Each subscriber reads the stream from its own offset, kept in Redis per device of the user, and a single reader
+// per replica reads the streams of all of its users. A subscriber too slow to keep up is paused at its offset
+// instead of being dropped, and gets the next entries once it makes room. Offsets only move past the entries
+// written to the device, so entries still queued when it disconnects are delivered again on reconnect.
+type RedisStreamMessageService struct {
+	client      *redis.Client
+	publisher   Publisher
+	presence    PresenceService
+	maxLen      int64
+	ttl         time.Duration
+	wakeStream  string // Stream written to when a user connects, so the reader starts reading the user's stream
+	connections map[string]*RedisStreamConnection
+	m           sync.Mutex
+	offsets     map[string]string // Offsets moved since they were last saved, by key
+	offsetsM    sync.Mutex
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+// NewRedisStreamMessageService starts reading the streams of the users of this replica.
+// Streams are capped to about maxLen entries, and streams and offsets are kept for ttl after their last update.
+func NewRedisStreamMessageService(client *redis.Client, publisher Publisher, presence PresenceService, instanceID string, maxLen int64, ttl time.Duration) *RedisStreamMessageService {
+	service := &RedisStreamMessageService{
+		client:      client,
+		publisher:   publisher,
+		presence:    presence,
+		maxLen:      maxLen,
+		ttl:         ttl,
+		wakeStream:  fmt.Sprintf("stream:replica:%s:wake", instanceID),
+		connections: make(map[string]*RedisStreamConnection),
+		offsets:     make(map[string]string),
+		done:        make(chan struct{}),
+	}
+	// Announcing the users left offline by crashed replicas
+	presence.OnExpired(func(ctx context.Context, userId string) {
+		notifyPresence(ctx, presence, service, userId)
+	})
+	go service.readStreams()
+	go service.saveOffsets()
+	return service
+}
+
+func userStream(userId string) string {
+	return fmt.Sprintf("stream:user:%s", userId)
+}
+
+func streamOffsetKey(userId string, device string) string {
+	return fmt.Sprintf("stream_offset:%s:%s", userId, device)
+}
+
+// SendMessage persists the message once through the message queue, then appends it to every destination's stream.
+func (service *RedisStreamMessageService) SendMessage(ctx context.Context, destinations []string, msg string) error {
+	config, _ := config.Get()
+	// Publishing message to message queue
+	err := service.publisher.Publish(ctx, msg, config.MsgQueue)
+	if err != nil {
+		return err
+	}
+	// Appending message to the destinations' streams for real-time delivery
+	return service.SendEphemeral(ctx, destinations, msg)
+}
+
+// SendEphemeral appends the message to every destination's stream in a single round trip, without persisting it.
+func (service *RedisStreamMessageService) SendEphemeral(ctx context.Context, destinations []string, msg string) error {
+	pipe := service.client.Pipeline()
+	for _, destination := range destinations {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: userStream(destination),
+			MaxLen: service.maxLen,
+			Approx: true,
+			Values: map[string]any{"event": msg},
+		})
+		pipe.Expire(ctx, userStream(destination), service.ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SubscribeToMessages subscribes to the user's stream, resuming after the last entry delivered to the device.
+// Subscribers without device, or devices without offset, read the stream from its end.
+func (service *RedisStreamMessageService) SubscribeToMessages(ctx context.Context, channel string, device string) (<-chan string, error) {
+	// Resuming from the last entry delivered to the device, or from the end of the stream if it is unknown or too old
+	var offsetKey, lastID string
+	var err error = redis.Nil
+	if device != "" {
+		offsetKey = streamOffsetKey(channel, device)
+		lastID, err = service.client.Get(ctx, offsetKey).Result()
+	}
+	if errors.Is(err, redis.Nil) {
+		lastID, err = service.lastEntryID(ctx, channel)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis get stream offset error: %w", err)
+	}
+
+	service.m.Lock()
+	conn, exists := service.connections[channel]
+	if !exists {
+		conn = NewRedisStreamConnection(userStream(channel))
+		service.connections[channel] = conn
+	}
+	subscriber := conn.NewSubscriber(offsetKey, lastID)
+	service.m.Unlock()
+
+	// Waking the reader up to read the stream from the new subscriber's offset
+	if err := service.wake(ctx); err != nil {
+		logger.GetLogger().Error("Failed to wake stream reader", zap.String("user_id", channel), zap.Error(err))
+	}
+
+	if !exists {
+		// First socket of the user on this replica, updating presence
+		if cameOnline, err := service.presence.Connect(ctx, channel); err != nil {
+			logger.GetLogger().Error("Failed to update presence", zap.String("user_id", channel), zap.Error(err))
+		} else if cameOnline {
+			notifyPresence(ctx, service.presence, service, channel)
+		}
+	}
+
+	return subscriber, nil
+}
+
+// wake interrupts the read of the streams, so it is started again with the current users of this replica.
+func (service *RedisStreamMessageService) wake(ctx context.Context) error {
+	pipe := service.client.Pipeline()
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: service.wakeStream,
+		MaxLen: 1,
+		Values: map[string]any{"wake": 1},
+	})
+	pipe.Expire(ctx, service.wakeStream, service.ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// lastEntryID returns the ID of the last entry of the user's stream, or the smallest ID if it is empty.
+func (service *RedisStreamMessageService) lastEntryID(ctx context.Context, userId string) (string, error) {
+	entries, err := service.client.XRevRangeN(ctx, userStream(userId), "+", "-", 1).Result()
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "0-0", nil
+	}
+	return entries[0].ID, nil
+}
+
+// Acknowledge moves the offset of the subscriber past msg, once it is written to the client or skipped.
+func (service *RedisStreamMessageService) Acknowledge(channel string, msgCh <-chan string, msg string) {
+	service.m.Lock()
+	conn, exists := service.connections[channel]
+	service.m.Unlock()
+	if !exists {
+		return
+	}
+	if key, offset, moved := conn.Ack(msgCh, msg); moved {
+		service.offsetsM.Lock()
+		service.offsets[key] = offset
+		service.offsetsM.Unlock()
+	}
+}
+
+func (service *RedisStreamMessageService) UnsubscribeFromMessages(channel string, msgCh <-chan string) error {
+	service.m.Lock()
+	conn, exists := service.connections[channel]
+	if !exists {
+		service.m.Unlock()
+		return fmt.Errorf("no connection found for channel %s", channel)
+	}
+	// Remove the subscriber channel, it may already be gone if the service was closed
+	offsetKey, offset, err := conn.RemoveSubscriber(msgCh)
+	if err == nil && offsetKey != "" {
+		// Saving the offset of the device right away, it may reconnect to another replica
+		service.offsetsM.Lock()
+		delete(service.offsets, offsetKey)
+		service.offsetsM.Unlock()
+		if saveErr := service.client.Set(context.Background(), offsetKey, offset, service.ttl).Err(); saveErr != nil {
+			logger.GetLogger().Error("Failed to save stream offset", zap.String("user_id", channel), zap.Error(saveErr))
+		}
+	}
+
+	// If there are no more subscribers, stop reading the user's stream, the offsets are kept to resume from
+	lastSubscriber := conn.SubscriberCount() <= 0
+	if lastSubscriber {
+		delete(service.connections, channel)
+	}
+	service.m.Unlock()
+
+	// Last socket of the user on this replica, updating presence
+	if lastSubscriber {
+		ctx := context.Background()
+		if wentOffline, presenceErr := service.presence.Disconnect(ctx, channel); presenceErr != nil {
+			logger.GetLogger().Error("Failed to update presence", zap.String("user_id", channel), zap.Error(presenceErr))
+		} else if wentOffline {
+			notifyPresence(ctx, service.presence, service, channel)
+		}
+	}
+
+	return err
+}
+
+// readStreams reads the streams of the users of this replica from their subscribers' offsets and delivers their entries,
+// until the service is closed.
+func (service *RedisStreamMessageService) readStreams() {
+	defer logger.GetLogger().Info("Stopped reading streams from Redis")
+	ctx := context.Background()
+	wakeID := "0-0"
+	for {
+		select {
+		case <-service.done:
+			return
+		default:
+		}
+
+		// Reading the wake stream along with the streams of the users with room for more entries, IDs following the streams
+		service.m.Lock()
+		streams := []string{service.wakeStream}
+		ids := []string{wakeID}
+		block := streamReadBlock
+		for userId, conn := range service.connections {
+			from, paused := conn.ReadFrom()
+			if paused {
+				block = streamPausedReadBlock
+			}
+			if from == "" {
+				continue
+			}
+			streams = append(streams, userStream(userId))
+			ids = append(ids, from)
+		}
+		service.m.Unlock()
+
+		results, err := service.client.XRead(ctx, &redis.XReadArgs{
+			Streams: append(streams, ids...),
+			Count:   streamReadCount,
+			Block:   block,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			logger.GetLogger().Error("Failed to read streams", zap.Error(err))
+			select {
+			case <-service.done:
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		service.m.Lock()
+		offsets := make(map[string]string)
+		for _, result := range results {
+			if result.Stream == service.wakeStream {
+				wakeID = result.Messages[len(result.Messages)-1].ID
+				continue
+			}
+			userId := strings.TrimPrefix(result.Stream, userStream(""))
+			// The user may have left this replica since the read
+			conn, exists := service.connections[userId]
+			if !exists {
+				continue
+			}
+			conn.Deliver(result.Messages, offsets)
+		}
+		service.m.Unlock()
+		if len(offsets) == 0 {
+			continue
+		}
+		service.offsetsM.Lock()
+		for key, offset := range offsets {
+			service.offsets[key] = offset
+		}
+		service.offsetsM.Unlock()
+	}
+}
+
+// saveOffsets saves the offsets moved since the last save at regular intervals, until the service is closed.
+func (service *RedisStreamMessageService) saveOffsets() {
+	ticker := time.NewTicker(streamOffsetSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-service.done:
+			return
+		case <-ticker.C:
+			service.flushOffsets()
+		}
+	}
+}
+
+// flushOffsets saves the offsets moved since the last save in a single round trip.
+func (service *RedisStreamMessageService) flushOffsets() {
+	service.offsetsM.Lock()
+	offsets := service.offsets
+	service.offsets = make(map[string]string)
+	service.offsetsM.Unlock()
+	if len(offsets) == 0 {
+		return
+	}
+
+	ctx := context.Background()
+	pipe := service.client.Pipeline()
+	for key, offset := range offsets {
+		pipe.Set(ctx, key, offset, service.ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.GetLogger().Error("Failed to save stream offsets", zap.Error(err))
+	}
+}
+
+func (service *RedisStreamMessageService) Close() error {
+	// Stop reading the streams, waking the reader up
+	service.closeOnce.Do(func() {
+		close(service.done)
+	})
+	service.wake(context.Background())
+
+	service.m.Lock()
+	defer service.m.Unlock()
+	// Close all connections, their offsets are saved for the users to resume on another replica
+	for _, conn := range service.connections {
+		conn.Close()
+	}
+	service.flushOffsets()
+	// Stop tracking presence of the users of this replica
+	if err := service.presence.Close(); err != nil {
+		return err
+	}
+	// Close the publisher
+	err := service.publisher.Close()
+	if err != nil {
+		return err
+	}
+	// Close the Redis client and return any error
+	return service.client.Close()
+}
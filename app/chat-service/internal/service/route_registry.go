@@ -0,0 +1,14 @@
+package service
+
+import "context"
+
+// RouteRegistry maps users to the replicas holding their sockets, so events are only published to those replicas.
+type RouteRegistry interface {
+	// Register routes the user to this replica, once per socket of the user.
+	Register(ctx context.Context, userId string) error
+	// Unregister stops routing the user to this replica once all of the user's sockets are gone.
+	Unregister(ctx context.Context, userId string) error
+	// Lookup returns the users hosted by each live replica, for the given users.
+	Lookup(ctx context.Context, userIds []string) (map[string][]string, error)
+	Close() error
+}
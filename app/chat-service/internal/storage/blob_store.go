@@ -0,0 +1,21 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrBlobNotFound is returned when no blob is stored under the requested key.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore stores opaque blobs, such as attachment contents, by key.
+// The local filesystem backend is the first one, S3-compatible object stores can implement it too.
+type BlobStore interface {
+	// Put stores the content read from r under the key and returns its size, nothing is stored if reading fails.
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	// Get returns the content stored under the key, to be closed by the caller, or ErrBlobNotFound.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob stored under the key, deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+}
@@ -3,8 +3,14 @@ package utils
 import (
 	"fmt"
 	"sort"
+	"strings"
+
+	"github.com/google/uuid"
 )
 
+// Prefix of group conversation IDs, direct conversation IDs are made of two sorted user IDs instead.
+const groupConvIdPrefix = "group:"
+
 // GenerateConvId generates consistent hashing for conversation id based on sender and destination.
 func GenerateConvId(sender string, destination string) string {
 	// Sorting users so order doesn't matter
@@ -13,3 +19,18 @@ func GenerateConvId(sender string, destination string) string {
 	// Joining users with ":" to form a unique conversation
 	return fmt.Sprintf("%s:%s", users[0], users[1])
 }
+
+// ParseConvId returns the two users of a direct conversation id.
+func ParseConvId(conversationID string) []string {
+	return strings.SplitN(conversationID, ":", 2)
+}
+
+// GenerateGroupConvId generates a new unique conversation id for a group.
+func GenerateGroupConvId() string {
+	return groupConvIdPrefix + uuid.New().String()
+}
+
+// IsGroupConvId reports whether the conversation id belongs to a group conversation.
+func IsGroupConvId(conversationID string) bool {
+	return strings.HasPrefix(conversationID, groupConvIdPrefix)
+}
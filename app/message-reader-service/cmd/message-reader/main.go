@@ -83,6 +83,11 @@ func main() {
 	// Preparing for message service creation
 	conversationRepo := repository.NewMongoConversationRepository(mongoClient, config.MongoDBName, config.MongoCollectionName, config.MessagePageSize)
 	conversationCacheRepo := repository.NewRedisConversationCacheRepository(redisClient, config.CacheTTL)
+	groupRepo := repository.NewMongoGroupRepository(mongoClient, config.MongoDBName, config.MongoGroupCollection)
+	receiptRepo := repository.NewMongoReceiptRepository(mongoClient, config.MongoDBName, config.MongoReceiptCollection)
+	inboxRepo := repository.NewMongoInboxRepository(mongoClient, config.MongoDBName, config.MongoInboxCollection, config.InboxPageSize)
+	blockRepo := repository.NewMongoBlockRepository(mongoClient, config.MongoDBName, config.MongoBlockCollection)
+	settingsRepo := repository.NewMongoNotificationSettingsRepository(mongoClient, config.MongoDBName, config.MongoNotifyCollection)
 
 	// Preparing cache update worker
 	cacheUpdateProcessor := processor.NewCacheUpdateProcessor(conversationCacheRepo)
@@ -90,7 +95,7 @@ func main() {
 	cacheUpdateWorkerPool := worker.NewWorkerPool[model.Message](amqpSource, cacheUpdateProcessor, config.WorkerPoolSize)
 
 	// Create gRPC server with already registered handlers
-	s := server.NewMessageGRPCServer(conversationRepo, conversationCacheRepo)
+	s := server.NewMessageGRPCServer(conversationRepo, conversationCacheRepo, groupRepo, receiptRepo, inboxRepo, blockRepo, settingsRepo)
 
 	// Start serving
 	go func() {
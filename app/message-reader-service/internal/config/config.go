@@ -12,24 +12,30 @@ import (
 
 // Config holds the configuration values for the application.
 type Config struct {
-	Port                string
-	CacheTTL            time.Duration
-	MessagePageSize     int
-	MongoDBName         string
-	MongoCollectionName string
-	MongoAddr           string
-	MongoUser           string
-	MongoPass           string
-	MongoTimeout        string
-	RedisAddr           string
-	RedisPass           string
-	RedisDB             int
-	AMQPUser            string
-	AMQPPass            string
-	AMQPHost            string
-	MsgQueue            string
-	MsgExchange         string
-	WorkerPoolSize      int
+	Port                   string
+	CacheTTL               time.Duration
+	MessagePageSize        int
+	InboxPageSize          int
+	MongoDBName            string
+	MongoCollectionName    string
+	MongoGroupCollection   string
+	MongoReceiptCollection string
+	MongoInboxCollection   string
+	MongoBlockCollection   string
+	MongoNotifyCollection  string
+	MongoAddr              string
+	MongoUser              string
+	MongoPass              string
+	MongoTimeout           string
+	RedisAddr              string
+	RedisPass              string
+	RedisDB                int
+	AMQPUser               string
+	AMQPPass               string
+	AMQPHost               string
+	MsgQueue               string
+	MsgExchange            string
+	WorkerPoolSize         int
 }
 
 var (
@@ -39,7 +45,7 @@ var (
 
 // Get returns the singleton instance of Config, it reads the configs only once.
 func Get() *Config {
-	var messagesPageSize, redisDB, workerPoolSize int = 20, 0, 10 // default value
+	var messagesPageSize, inboxPageSize, redisDB, workerPoolSize int = 20, 20, 0, 10 // default value
 	var cacheTTLDefault time.Duration = 6 * time.Hour
 	var err error
 	redisDB, err = strconv.Atoi(getEnv("REDIS_DB", "0"))
@@ -50,6 +56,12 @@ func Get() *Config {
 	if err != nil {
 		logger.Get().Error("Invalid MESSAGE_PAGE_SIZE, using default", zap.Int("value", messagesPageSize), zap.Error(err))
 	}
+	inboxPageSizeEnv := getEnv("INBOX_PAGE_SIZE", "20")
+	if val, err := strconv.Atoi(inboxPageSizeEnv); err == nil {
+		inboxPageSize = val
+	} else {
+		logger.Get().Error("Invalid INBOX_PAGE_SIZE, using default", zap.String("value", inboxPageSizeEnv), zap.Error(err))
+	}
 	cacheTTL, err := time.ParseDuration(getEnv("CACHE_TTL_HOURS", "6h"))
 	if err != nil {
 		cacheTTL = cacheTTLDefault
@@ -66,24 +78,30 @@ func Get() *Config {
 
 	once.Do(func() {
 		instance = &Config{
-			Port:                getEnv("PORT", "50051"),
-			CacheTTL:            cacheTTL,
-			MessagePageSize:     messagesPageSize,
-			MongoDBName:         getEnv("MONGO_DB_NAME", "darda_chat"),
-			MongoCollectionName: getEnv("MONGO_COLLECTION_NAME", "messages"),
-			MongoAddr:           getEnv("MONGO_ADDR", "mongodb://localhost:27017"),
-			MongoTimeout:        getEnv("MONGO_TIMEOUT", "10s"),
-			MongoUser:           getEnv("MONGO_USER", "root"),
-			MongoPass:           getEnv("MONGO_PASS", ""),
-			RedisAddr:           getEnv("REDIS_ADDR", "localhost:6379"),
-			RedisPass:           getEnv("REDIS_PASS", ""),
-			RedisDB:             redisDB,
-			WorkerPoolSize:      workerPoolSize,
-			AMQPUser:            getEnv("AMQP_USER", ""),
-			AMQPPass:            getEnv("AMQP_PASS", ""),
-			AMQPHost:            getEnv("AMQP_HOST", ""),
-			MsgQueue:            getEnv("MSG_QUEUE", "conversation.cache"),
-			MsgExchange:         getEnv("MSG_EXCHANGE", "message.dispatched"),
+			Port:                   getEnv("PORT", "50051"),
+			CacheTTL:               cacheTTL,
+			MessagePageSize:        messagesPageSize,
+			InboxPageSize:          inboxPageSize,
+			MongoDBName:            getEnv("MONGO_DB_NAME", "darda_chat"),
+			MongoCollectionName:    getEnv("MONGO_COLLECTION_NAME", "messages"),
+			MongoGroupCollection:   getEnv("MONGO_GROUP_COLLECTION_NAME", "groups"),
+			MongoReceiptCollection: getEnv("MONGO_RECEIPT_COLLECTION_NAME", "receipts"),
+			MongoInboxCollection:   getEnv("MONGO_INBOX_COLLECTION_NAME", "inbox"),
+			MongoBlockCollection:   getEnv("MONGO_BLOCK_COLLECTION_NAME", "blocks"),
+			MongoNotifyCollection:  getEnv("MONGO_NOTIFICATION_COLLECTION_NAME", "notification_settings"),
+			MongoAddr:              getEnv("MONGO_ADDR", "mongodb://localhost:27017"),
+			MongoTimeout:           getEnv("MONGO_TIMEOUT", "10s"),
+			MongoUser:              getEnv("MONGO_USER", "root"),
+			MongoPass:              getEnv("MONGO_PASS", ""),
+			RedisAddr:              getEnv("REDIS_ADDR", "localhost:6379"),
+			RedisPass:              getEnv("REDIS_PASS", ""),
+			RedisDB:                redisDB,
+			WorkerPoolSize:         workerPoolSize,
+			AMQPUser:               getEnv("AMQP_USER", ""),
+			AMQPPass:               getEnv("AMQP_PASS", ""),
+			AMQPHost:               getEnv("AMQP_HOST", ""),
+			MsgQueue:               getEnv("MSG_QUEUE", "conversation.cache"),
+			MsgExchange:            getEnv("MSG_EXCHANGE", "message.dispatched"),
 		}
 	})
 	return instance
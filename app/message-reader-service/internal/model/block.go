@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// Represents a user blocking another user, unblocked users being kept with Blocked false
+type Block struct {
+	UserID        string    `json:"user_id" bson:"userId"` // User who blocks
+	BlockedUserID string    `json:"blocked_user_id" bson:"blockedUserId"`
+	Blocked       bool      `json:"blocked" bson:"blocked"`
+	UpdatedAt     time.Time `json:"updated_at" bson:"updatedAt"`
+}
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// Represents a conversation in the inbox of a user, with its last message and the messages the user didn't read
+type ConversationSummary struct {
+	ID             string           `json:"id" bson:"_id"`
+	UserID         string           `json:"user_id" bson:"userId"`
+	ConversationID string           `json:"conversation_id" bson:"conversationId"`
+	LastMessage    *Message         `json:"last_message,omitempty" bson:"lastMessage,omitempty"` // Content truncated, nil for conversations without messages
+	LastActivityAt time.Time        `json:"last_activity_at" bson:"lastActivityAt"`
+	UnreadCount    int64            `json:"unread_count" bson:"unreadCount"`
+	LastRead       *ReceiptPosition `json:"last_read,omitempty" bson:"lastRead,omitempty"` // Nil if the user never read the conversation
+}
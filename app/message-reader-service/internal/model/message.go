@@ -4,10 +4,22 @@ import "time"
 
 // Represents an actual chat message
 type Message struct {
-	ID             string    `json:"id" bson:"_id"`
-	ConversationID string    `json:"conversation_id" bson:"conversationId"`
-	Sender         string    `json:"sender" bson:"sender"`
-	Destination    string    `json:"destination" bson:"destination"`
-	Content        string    `json:"content" bson:"content"`
-	Timestamp      time.Time `json:"timestamp" bson:"timestamp"`
+	ID             string              `json:"id" bson:"_id"`
+	ConversationID string              `json:"conversation_id" bson:"conversationId"`
+	Sender         string              `json:"sender" bson:"sender"`
+	Destination    string              `json:"destination" bson:"destination"`
+	Content        string              `json:"content" bson:"content"`
+	Timestamp      time.Time           `json:"timestamp" bson:"timestamp"`
+	EditedAt       time.Time           `json:"edited_at,omitzero" bson:"editedAt,omitempty"`
+	DeletedAt      time.Time           `json:"deleted_at,omitzero" bson:"deletedAt,omitempty"` // Set when deleted for everyone, the content is then empty
+	DeletedBy      string              `json:"deleted_by,omitempty" bson:"deletedBy,omitempty"`
+	HiddenFor      []string            `json:"hidden_for,omitempty" bson:"hiddenFor,omitempty"`        // Users who deleted the message for themselves
+	Reactions      map[string][]string `json:"reactions,omitempty" bson:"reactions,omitempty"`         // Users who reacted, by reaction
+	ReplyTo        string              `json:"reply_to,omitempty" bson:"replyTo,omitempty"`            // Message quoted by this one
+	ThreadRootID   string              `json:"thread_root_id,omitempty" bson:"threadRootId,omitempty"` // Root of the thread the message replies in
+	ReplyCount     int64               `json:"reply_count,omitempty" bson:"replyCount,omitempty"`      // Replies in the thread of a root message
+	LastReplyAt    time.Time           `json:"last_reply_at,omitzero" bson:"lastReplyAt,omitempty"`    // Time of the latest reply in the thread
+	Attachments    []Attachment        `json:"attachments,omitempty" bson:"attachments,omitempty"`     // Metadata of the files attached to the message
+	Mentions       []string            `json:"mentions,omitempty" bson:"mentions,omitempty"`           // Participants mentioned by the message
+	Version        int64               `json:"version" bson:"version"`                                 // Incremented by each change, newer versions replace cached ones
 }
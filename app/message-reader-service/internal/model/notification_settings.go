@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// Notification level of conversations whose settings were never changed
+const NotifyAll = "all"
+
+// Represents how a user is notified of the messages of a conversation
+type NotificationSettings struct {
+	UserID         string    `json:"user_id" bson:"userId"`
+	ConversationID string    `json:"conversation_id" bson:"conversationId"`
+	Level          string    `json:"level" bson:"level"`                               // "all", "mentions" or "muted"
+	MutedUntil     time.Time `json:"muted_until,omitzero" bson:"mutedUntil,omitempty"` // Zero if muted until changed, or not muted
+	UpdatedAt      time.Time `json:"updated_at" bson:"updatedAt"`
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// Represents the latest delivery and read positions of a participant in a conversation
+type Receipt struct {
+	ID             string           `json:"id" bson:"_id"`
+	ConversationID string           `json:"conversation_id" bson:"conversationId"`
+	UserID         string           `json:"user_id" bson:"userId"`
+	Delivered      *ReceiptPosition `json:"delivered,omitempty" bson:"delivered,omitempty"`
+	Read           *ReceiptPosition `json:"read,omitempty" bson:"read,omitempty"`
+}
+
+// Represents the last message a receipt was reported for
+type ReceiptPosition struct {
+	MessageID        string    `json:"message_id" bson:"messageId"`
+	MessageTimestamp time.Time `json:"message_timestamp" bson:"messageTimestamp"`
+	ReportedAt       time.Time `json:"reported_at" bson:"reportedAt"`
+}
@@ -18,7 +18,11 @@ func NewCacheUpdateProcessor(repository repository.ConversationCacheRepository)
 	}
 }
 
+// Process appends new messages to the latest messages of their conversation, and replaces changed ones wherever they are cached.
 func (p *CacheUpdateProcessor) Process(ctx context.Context, event *model.Message) error {
+	if event.Version > 0 {
+		return p.repository.UpdateConversationMessage(event)
+	}
 	convKey := fmt.Sprintf("conversation:%s:", event.ConversationID)
 	return p.repository.SetConversationMessage(convKey, event)
 }
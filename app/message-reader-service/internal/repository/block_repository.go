@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/nsmsb/darda-chat/app/message-reader-service/internal/model"
+)
+
+type BlockRepository interface {
+	// ListBlocks retrieves the users currently blocked by a user, most recently blocked first.
+	ListBlocks(ctx context.Context, userID string) ([]*model.Block, error)
+	// ListBlockers retrieves the users among the given ones who currently block a user.
+	ListBlockers(ctx context.Context, userID string, users []string) ([]string, error)
+}
@@ -9,6 +9,8 @@ type ConversationCacheRepository interface {
 	SetConversationMessage(conversationKey string, messages *model.Message) error
 	// Set a cache of conversation messages
 	SetConversationMessages(conversationKey string, messages []*model.Message) error
+	// Replace the cached entries of a changed message, if older
+	UpdateConversationMessage(message *model.Message) error
 	// Get cached conversation messages
 	GetConversationMessages(conversationKey string) ([]*model.Message, error)
 }
@@ -9,4 +9,8 @@ import (
 type ConversationRepository interface {
 	// GetConversation retrieves messages for a given conversation ID and before/after cursors.
 	GetConversationMessages(ctx context.Context, conversationID string, before string, after string) ([]*model.Message, error)
+	// GetThreadMessages retrieves replies in the thread of a root message for before/after cursors.
+	GetThreadMessages(ctx context.Context, threadRootID string, before string, after string) ([]*model.Message, error)
+	// GetMessage retrieves a message by its ID.
+	GetMessage(ctx context.Context, messageID string) (*model.Message, error)
 }
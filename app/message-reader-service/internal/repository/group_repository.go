@@ -0,0 +1,12 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/nsmsb/darda-chat/app/message-reader-service/internal/model"
+)
+
+type GroupRepository interface {
+	// GetGroup retrieves a group conversation with its members.
+	GetGroup(ctx context.Context, groupID string) (*model.Group, error)
+}
@@ -0,0 +1,12 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/nsmsb/darda-chat/app/message-reader-service/internal/model"
+)
+
+type InboxRepository interface {
+	// ListConversations retrieves a page of the conversations of a user, most recently active first, before a cursor.
+	ListConversations(ctx context.Context, userID string, before string) ([]*model.ConversationSummary, error)
+}
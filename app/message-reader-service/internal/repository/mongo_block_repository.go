@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/nsmsb/darda-chat/app/message-reader-service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type MongoBlockRepository struct {
+	client         *mongo.Client
+	dbName         string
+	collectionName string
+	collection     *mongo.Collection
+}
+
+// NewMongoBlockRepository creates a new instance of MongoBlockRepository.
+func NewMongoBlockRepository(client *mongo.Client, dbName string, collectionName string) *MongoBlockRepository {
+	return &MongoBlockRepository{
+		client:         client,
+		dbName:         dbName,
+		collectionName: collectionName,
+		collection:     client.Database(dbName).Collection(collectionName),
+	}
+}
+
+// ListBlocks retrieves the blocks of a user, unblocked users being left out.
+func (r *MongoBlockRepository) ListBlocks(ctx context.Context, userID string) ([]*model.Block, error) {
+	filter := bson.M{"userId": userID, "blocked": true}
+	opts := options.Find().SetSort(bson.D{{Key: "updatedAt", Value: -1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "mongo find error: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var blocks []*model.Block
+	if err := cursor.All(ctx, &blocks); err != nil {
+		return nil, status.Errorf(codes.Internal, "cursor decode error: %v", err)
+	}
+	return blocks, nil
+}
+
+// ListBlockers retrieves the users among the given ones who block a user.
+func (r *MongoBlockRepository) ListBlockers(ctx context.Context, userID string, users []string) ([]string, error) {
+	if len(users) == 0 {
+		return nil, nil
+	}
+	filter := bson.M{"blockedUserId": userID, "blocked": true, "userId": bson.M{"$in": users}}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"userId": 1}))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "mongo find error: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var blocks []*model.Block
+	if err := cursor.All(ctx, &blocks); err != nil {
+		return nil, status.Errorf(codes.Internal, "cursor decode error: %v", err)
+	}
+	blockers := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		blockers = append(blockers, block.UserID)
+	}
+	return blockers, nil
+}
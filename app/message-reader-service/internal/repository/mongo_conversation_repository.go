@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"slices"
 	"strings"
 	"time"
@@ -42,16 +43,35 @@ func (r *MongoConversationRepository) GetConversationMessages(ctx context.Contex
 	log := logger.FromContext(ctx)
 	log.Info("Fetching conversation messages from MongoDB", zap.String("conversationID", conversationID), zap.String("before", before), zap.String("after", after))
 
-	// Handling error when both after and before cursors are set
-	if before != "" && after != "" {
-		return nil, status.Error(codes.InvalidArgument, "only one of 'before' or 'after' can be set")
-	}
-
 	// MongoDB filter (by conversationId)
 	filter := bson.M{
 		"conversationId": conversationID,
 	}
 
+	return r.findMessagesPage(ctx, filter, before, after)
+}
+
+// GetThreadMessages retrieves replies in the thread of a root message for before/after cursors,
+// with the same cursors as GetConversationMessages. When both are empty, it retrieves the latest replies.
+func (r *MongoConversationRepository) GetThreadMessages(ctx context.Context, threadRootID string, before string, after string) ([]*model.Message, error) {
+	log := logger.FromContext(ctx)
+	log.Info("Fetching thread messages from MongoDB", zap.String("threadRootID", threadRootID), zap.String("before", before), zap.String("after", after))
+
+	// MongoDB filter (by threadRootId)
+	filter := bson.M{
+		"threadRootId": threadRootID,
+	}
+
+	return r.findMessagesPage(ctx, filter, before, after)
+}
+
+// findMessagesPage retrieves a page of the messages matching the filter, before or after a timestamp and ID cursor.
+func (r *MongoConversationRepository) findMessagesPage(ctx context.Context, filter bson.M, before string, after string) ([]*model.Message, error) {
+	// Handling error when both after and before cursors are set
+	if before != "" && after != "" {
+		return nil, status.Error(codes.InvalidArgument, "only one of 'before' or 'after' can be set")
+	}
+
 	// Adding cursor conditions
 	if before != "" {
 		splittedCursor := strings.SplitN(before, "_", 2)
@@ -106,8 +126,13 @@ func (r *MongoConversationRepository) GetConversationMessages(ctx context.Contex
 	}
 
 	// Setting find options: newest first, limit set to pageSize
+	// Pages after a cursor start right after it instead, so paging forward doesn't skip messages
+	order := -1
+	if after != "" {
+		order = 1
+	}
 	opts := options.Find().
-		SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSort(bson.D{{Key: "timestamp", Value: order}, {Key: "_id", Value: order}}).
 		SetLimit(int64(r.pageSize))
 
 	// Executing find query
@@ -124,7 +149,22 @@ func (r *MongoConversationRepository) GetConversationMessages(ctx context.Contex
 	}
 
 	// Reverse back to oldest → newest (UI-friendly)
-	slices.Reverse(messages)
+	if order < 0 {
+		slices.Reverse(messages)
+	}
 
 	return messages, nil
 }
+
+// GetMessage retrieves a message by its ID.
+func (r *MongoConversationRepository) GetMessage(ctx context.Context, messageID string) (*model.Message, error) {
+	var message model.Message
+	err := r.collection.FindOne(ctx, bson.M{"_id": messageID}).Decode(&message)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, status.Errorf(codes.NotFound, "message %s not found", messageID)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "mongo find message error: %v", err)
+	}
+	return &message, nil
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nsmsb/darda-chat/app/message-reader-service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type MongoGroupRepository struct {
+	client         *mongo.Client
+	dbName         string
+	collectionName string
+	collection     *mongo.Collection
+}
+
+// NewMongoGroupRepository creates a new instance of MongoGroupRepository.
+func NewMongoGroupRepository(client *mongo.Client, dbName string, collectionName string) *MongoGroupRepository {
+	return &MongoGroupRepository{
+		client:         client,
+		dbName:         dbName,
+		collectionName: collectionName,
+		collection:     client.Database(dbName).Collection(collectionName),
+	}
+}
+
+// GetGroup retrieves a group conversation by its ID.
+func (r *MongoGroupRepository) GetGroup(ctx context.Context, groupID string) (*model.Group, error) {
+	var group model.Group
+	err := r.collection.FindOne(ctx, bson.M{"_id": groupID}).Decode(&group)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, status.Errorf(codes.NotFound, "group %s not found", groupID)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "mongo find group error: %v", err)
+	}
+	return &group, nil
+}
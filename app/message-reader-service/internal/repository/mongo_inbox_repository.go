@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/nsmsb/darda-chat/app/message-reader-service/internal/model"
+	"github.com/nsmsb/darda-chat/app/message-reader-service/pkg/logger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type MongoInboxRepository struct {
+	client         *mongo.Client
+	dbName         string
+	collectionName string
+	pageSize       int
+	collection     *mongo.Collection
+}
+
+// NewMongoInboxRepository creates a new instance of MongoInboxRepository.
+func NewMongoInboxRepository(client *mongo.Client, dbName string, collectionName string, pageSize int) *MongoInboxRepository {
+	return &MongoInboxRepository{
+		client:         client,
+		dbName:         dbName,
+		collectionName: collectionName,
+		pageSize:       pageSize,
+		collection:     client.Database(dbName).Collection(collectionName),
+	}
+}
+
+// ListConversations retrieves the conversations of a user ordered by last activity then conversation ID, newest first.
+// The before cursor is the last activity time and conversation ID of the last conversation of the previous page.
+func (r *MongoInboxRepository) ListConversations(ctx context.Context, userID string, before string) ([]*model.ConversationSummary, error) {
+	log := logger.FromContext(ctx)
+	log.Info("Fetching conversations from MongoDB", zap.String("userID", userID), zap.String("before", before))
+
+	filter := bson.M{
+		"userId": userID,
+	}
+
+	if before != "" {
+		splittedCursor := strings.SplitN(before, "_", 2)
+		if len(splittedCursor) != 2 {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid before cursor format")
+		}
+
+		cursorTs, cursorID := splittedCursor[0], splittedCursor[1]
+
+		t, err := time.Parse(time.RFC3339Nano, cursorTs)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid before timestamp: %v", err)
+		}
+		beforeTime := primitive.NewDateTimeFromTime(t)
+
+		filter["$or"] = []bson.M{
+			{
+				"lastActivityAt": bson.M{"$lt": beforeTime},
+			},
+			{
+				"lastActivityAt": beforeTime,
+				"conversationId": bson.M{"$lt": cursorID},
+			},
+		}
+	}
+
+	// Most recently active first, limit set to pageSize
+	opts := options.Find().
+		SetSort(bson.D{{Key: "lastActivityAt", Value: -1}, {Key: "conversationId", Value: -1}}).
+		SetLimit(int64(r.pageSize))
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "mongo find error: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var conversations []*model.ConversationSummary
+	if err := cursor.All(ctx, &conversations); err != nil {
+		return nil, status.Errorf(codes.Internal, "cursor decode error: %v", err)
+	}
+	return conversations, nil
+}
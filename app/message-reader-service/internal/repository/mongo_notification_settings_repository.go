@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nsmsb/darda-chat/app/message-reader-service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type MongoNotificationSettingsRepository struct {
+	client         *mongo.Client
+	dbName         string
+	collectionName string
+	collection     *mongo.Collection
+}
+
+// NewMongoNotificationSettingsRepository creates a new instance of MongoNotificationSettingsRepository.
+func NewMongoNotificationSettingsRepository(client *mongo.Client, dbName string, collectionName string) *MongoNotificationSettingsRepository {
+	return &MongoNotificationSettingsRepository{
+		client:         client,
+		dbName:         dbName,
+		collectionName: collectionName,
+		collection:     client.Database(dbName).Collection(collectionName),
+	}
+}
+
+// GetSettings retrieves notification settings by their IDs, made of the user and conversation IDs as those of inbox summaries.
+func (r *MongoNotificationSettingsRepository) GetSettings(ctx context.Context, userID string, conversationIDs []string) ([]*model.NotificationSettings, error) {
+	if len(conversationIDs) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, 0, len(conversationIDs))
+	for _, conversationID := range conversationIDs {
+		ids = append(ids, fmt.Sprintf("%s|%s", userID, conversationID))
+	}
+
+	cursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "mongo find error: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var settings []*model.NotificationSettings
+	if err := cursor.All(ctx, &settings); err != nil {
+		return nil, status.Errorf(codes.Internal, "cursor decode error: %v", err)
+	}
+	return settings, nil
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/nsmsb/darda-chat/app/message-reader-service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type MongoReceiptRepository struct {
+	client         *mongo.Client
+	dbName         string
+	collectionName string
+	collection     *mongo.Collection
+}
+
+// NewMongoReceiptRepository creates a new instance of MongoReceiptRepository.
+func NewMongoReceiptRepository(client *mongo.Client, dbName string, collectionName string) *MongoReceiptRepository {
+	return &MongoReceiptRepository{
+		client:         client,
+		dbName:         dbName,
+		collectionName: collectionName,
+		collection:     client.Database(dbName).Collection(collectionName),
+	}
+}
+
+// GetReceipts retrieves the receipts of all participants of a conversation.
+func (r *MongoReceiptRepository) GetReceipts(ctx context.Context, conversationID string) ([]*model.Receipt, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"conversationId": conversationID})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "mongo find error: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var receipts []*model.Receipt
+	if err := cursor.All(ctx, &receipts); err != nil {
+		return nil, status.Errorf(codes.Internal, "cursor decode error: %v", err)
+	}
+	return receipts, nil
+}
@@ -0,0 +1,12 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/nsmsb/darda-chat/app/message-reader-service/internal/model"
+)
+
+type NotificationSettingsRepository interface {
+	// GetSettings retrieves the notification settings of a user for each of the given conversations whose settings they changed.
+	GetSettings(ctx context.Context, userID string, conversationIDs []string) ([]*model.NotificationSettings, error)
+}
@@ -0,0 +1,12 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/nsmsb/darda-chat/app/message-reader-service/internal/model"
+)
+
+type ReceiptRepository interface {
+	// GetReceipts retrieves the delivery and read positions of every participant of a conversation.
+	GetReceipts(ctx context.Context, conversationID string) ([]*model.Receipt, error)
+}
@@ -22,50 +22,98 @@ func NewRedisConversationCacheRepository(client *redis.Client, cacheTTL time.Dur
 	}
 }
 
-func (r *RedisConversationCacheRepository) SetConversationMessage(conversationKey string, messages *model.Message) error {
-	ctx := context.Background()
-
-	jsonMsg, err := json.Marshal(messages)
-	if err != nil {
-		return fmt.Errorf("message json marshal error: %w", err)
-	}
-
-	// Using a Pipe to add message and set expiration atomically
-	pipe := r.client.Pipeline()
-
-	pipe.RPush(ctx, conversationKey, jsonMsg)
-	pipe.Expire(ctx, conversationKey, r.cacheTTL)
+// appendMessagesScript appends messages to a cached conversation, skipping those whose ID is already in its ID set,
+// so redelivered or reloaded messages never show twice.
+// KEYS[1] is the list of messages, KEYS[2] the set of their IDs, KEYS[3] the optional index of the conversation's lists,
+// ARGV[1] the TTL in ms followed by ID and JSON pairs.
+var appendMessagesScript = redis.NewScript(`
+for i = 2, #ARGV, 2 do
+	if redis.call("SADD", KEYS[2], ARGV[i]) == 1 then
+		redis.call("RPUSH", KEYS[1], ARGV[i + 1])
+	end
+end
+redis.call("PEXPIRE", KEYS[1], ARGV[1])
+redis.call("PEXPIRE", KEYS[2], ARGV[1])
+if #KEYS == 3 then
+	redis.call("SADD", KEYS[3], KEYS[1])
+	redis.call("PEXPIRE", KEYS[3], ARGV[1])
+end
+return 0
+`)
+
+// updateMessageScript replaces the cached entry of a message if the cached version is older.
+// KEYS[1] is the list of messages, KEYS[2] the set of their IDs, ARGV[1] the message ID, ARGV[2] its version and ARGV[3] its JSON.
+var updateMessageScript = redis.NewScript(`
+if redis.call("SISMEMBER", KEYS[2], ARGV[1]) == 0 then
+	return 0
+end
+local entries = redis.call("LRANGE", KEYS[1], 0, -1)
+for i, entry in ipairs(entries) do
+	local msg = cjson.decode(entry)
+	if msg.id == ARGV[1] then
+		if (tonumber(msg.version) or 0) < tonumber(ARGV[2]) then
+			redis.call("LSET", KEYS[1], i - 1, ARGV[3])
+			return 1
+		end
+		return 0
+	end
+end
+return 0
+`)
+
+// conversationKeysKey is the set of the cached lists holding messages of a conversation.
+func conversationKeysKey(conversationID string) string {
+	return fmt.Sprintf("conversation:%s:keys", conversationID)
+}
 
-	_, err = pipe.Exec(ctx)
-	if err != nil {
-		return fmt.Errorf("redis pipeline exec error: %w", err)
-	}
-	return nil
+func (r *RedisConversationCacheRepository) SetConversationMessage(conversationKey string, messages *model.Message) error {
+	return r.SetConversationMessages(conversationKey, []*model.Message{messages})
 }
 
 func (r *RedisConversationCacheRepository) SetConversationMessages(conversationKey string, messages []*model.Message) error {
 	ctx := context.Background()
 
-	// Create a redis pipeline
-	pipe := r.client.Pipeline()
-
-	// Adding messages to pipeline
+	// Passing messages as ID and JSON pairs, keeping their natural order
+	args := make([]any, 0, 1+2*len(messages))
+	args = append(args, r.cacheTTL.Milliseconds())
 	for _, msg := range messages {
 		jsonMsg, err := json.Marshal(msg)
 		if err != nil {
 			return fmt.Errorf("message json marshal error: %w", err)
 		}
-		// Using RPUSH to keep a natural order of messages
-		pipe.RPush(ctx, conversationKey, jsonMsg)
+		args = append(args, msg.ID, jsonMsg)
 	}
 
-	// Setting expiration for the conversation messages
-	pipe.Expire(ctx, conversationKey, r.cacheTTL)
+	// Adding messages and setting expiration atomically, indexing the list so changed messages can be found
+	keys := []string{conversationKey, conversationKey + ":ids"}
+	if len(messages) > 0 {
+		keys = append(keys, conversationKeysKey(messages[0].ConversationID))
+	}
+	err := appendMessagesScript.Run(ctx, r.client, keys, args...).Err()
+	if err != nil {
+		return fmt.Errorf("redis append messages error: %w", err)
+	}
+	return nil
+}
+
+// UpdateConversationMessage replaces the message in every cached list of its conversation holding an older version of it.
+func (r *RedisConversationCacheRepository) UpdateConversationMessage(message *model.Message) error {
+	ctx := context.Background()
 
-	// Executing the pipeline
-	_, err := pipe.Exec(ctx)
+	jsonMsg, err := json.Marshal(message)
 	if err != nil {
-		return fmt.Errorf("redis pipeline exec error: %w", err)
+		return fmt.Errorf("message json marshal error: %w", err)
+	}
+
+	conversationKeys, err := r.client.SMembers(ctx, conversationKeysKey(message.ConversationID)).Result()
+	if err != nil {
+		return fmt.Errorf("redis smembers error: %w", err)
+	}
+	for _, conversationKey := range conversationKeys {
+		err := updateMessageScript.Run(ctx, r.client, []string{conversationKey, conversationKey + ":ids"}, message.ID, message.Version, jsonMsg).Err()
+		if err != nil {
+			return fmt.Errorf("redis update message error: %w", err)
+		}
 	}
 	return nil
 }
@@ -10,7 +10,7 @@ import (
 )
 
 // NewMessageGRPCServer creates and returns a new gRPC server with registered message service and interceptors.
-func NewMessageGRPCServer(conversationRepo repository.ConversationRepository, conversationCacheRepo repository.ConversationCacheRepository) *grpc.Server {
+func NewMessageGRPCServer(conversationRepo repository.ConversationRepository, conversationCacheRepo repository.ConversationCacheRepository, groupRepo repository.GroupRepository, receiptRepo repository.ReceiptRepository, inboxRepo repository.InboxRepository, blockRepo repository.BlockRepository, settingsRepo repository.NotificationSettingsRepository) *grpc.Server {
 	logger := logger.Get()
 
 	// Create server and add interceptors
@@ -30,7 +30,7 @@ func NewMessageGRPCServer(conversationRepo repository.ConversationRepository, co
 	)
 
 	// Creating message service
-	messageService := service.NewMessageService(conversationRepo, conversationCacheRepo)
+	messageService := service.NewMessageService(conversationRepo, conversationCacheRepo, groupRepo, receiptRepo, inboxRepo, blockRepo, settingsRepo)
 
 	// Register Message service
 	pb.RegisterMessageServiceServer(server, messageService)
@@ -3,8 +3,12 @@ package service
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
+	"time"
 
 	pb "github.com/nsmsb/darda-chat/app/message-reader-service/internal/api/message/gen"
+	"github.com/nsmsb/darda-chat/app/message-reader-service/internal/model"
 	"github.com/nsmsb/darda-chat/app/message-reader-service/internal/repository"
 	"github.com/nsmsb/darda-chat/app/message-reader-service/pkg/logger"
 	"go.uber.org/zap"
@@ -17,12 +21,22 @@ type MessageService struct {
 	pb.UnimplementedMessageServiceServer
 	conversationRepo      repository.ConversationRepository
 	conversationCacheRepo repository.ConversationCacheRepository
+	groupRepo             repository.GroupRepository
+	receiptRepo           repository.ReceiptRepository
+	inboxRepo             repository.InboxRepository
+	blockRepo             repository.BlockRepository
+	settingsRepo          repository.NotificationSettingsRepository
 }
 
-func NewMessageService(conversationRepo repository.ConversationRepository, conversationCacheRepo repository.ConversationCacheRepository) *MessageService {
+func NewMessageService(conversationRepo repository.ConversationRepository, conversationCacheRepo repository.ConversationCacheRepository, groupRepo repository.GroupRepository, receiptRepo repository.ReceiptRepository, inboxRepo repository.InboxRepository, blockRepo repository.BlockRepository, settingsRepo repository.NotificationSettingsRepository) *MessageService {
 	return &MessageService{
 		conversationRepo:      conversationRepo,
 		conversationCacheRepo: conversationCacheRepo,
+		groupRepo:             groupRepo,
+		receiptRepo:           receiptRepo,
+		inboxRepo:             inboxRepo,
+		blockRepo:             blockRepo,
+		settingsRepo:          settingsRepo,
 	}
 }
 
@@ -43,57 +57,419 @@ func (s *MessageService) GetMessages(ctx context.Context, request *pb.GetMessage
 		return nil, status.Error(codes.InvalidArgument, "only one of 'before' or 'after' can be set")
 	}
 
-	// Getting conversation messages from cache
-	convKey := fmt.Sprintf("conversation:%s:%s", conversationID, before+after)
-	messages, err := s.conversationCacheRepo.GetConversationMessages(convKey)
-	if err != nil {
-		return nil, err
-	}
-
-	// Load from database if cache miss
-	if len(messages) == 0 {
-		log.Info("Cache miss for conversation", zap.String("conversationKey", convKey))
-		// Getting conversation
+	var messages []*model.Message
+	var err error
+	if after != "" {
+		// Pages after a cursor grow with new messages, they are read from the database instead of being cached
 		messages, err = s.conversationRepo.GetConversationMessages(ctx, conversationID, before, after)
 		if err != nil {
 			return nil, err
 		}
-		// Setting cache
-		err = s.conversationCacheRepo.SetConversationMessages(convKey, messages)
+	} else {
+		// Getting conversation messages from cache
+		convKey := fmt.Sprintf("conversation:%s:%s", conversationID, before)
+		messages, err = s.conversationCacheRepo.GetConversationMessages(convKey)
 		if err != nil {
 			return nil, err
 		}
-		log.Info("Cache hit for conversation", zap.String("conversationKey", convKey), zap.Int("messageCount", len(messages)))
+
+		// Load from database if cache miss
+		if len(messages) == 0 {
+			log.Info("Cache miss for conversation", zap.String("conversationKey", convKey))
+			// Getting conversation
+			messages, err = s.conversationRepo.GetConversationMessages(ctx, conversationID, before, after)
+			if err != nil {
+				return nil, err
+			}
+			// Setting cache
+			err = s.conversationCacheRepo.SetConversationMessages(convKey, messages)
+			if err != nil {
+				return nil, err
+			}
+			log.Info("Cache hit for conversation", zap.String("conversationKey", convKey), zap.Int("messageCount", len(messages)))
+		}
+	}
+
+	// Pages are cached for all participants, messages of blocked senders are left out for each user afterwards
+	blocked, err := s.blockedUsers(ctx, request.GetUserId())
+	if err != nil {
+		return nil, err
 	}
 
-	// Convert to protobuf messages
-	conversation := make([]*pb.Message, 0, len(messages))
+	// Determine older and newer cursors before leaving messages out, so pages made only of hidden messages can be skipped
+	olderCursor, newerCursor := pageCursors(messages)
+
+	return &pb.GetMessagesResponse{
+		Messages: toProtoMessages(messages, request.GetUserId(), blocked),
+		Before:   olderCursor,
+		After:    newerCursor,
+	}, nil
+}
+
+// GetThread retrieves a root message and a page of the replies in its thread.
+func (s *MessageService) GetThread(ctx context.Context, request *pb.GetThreadRequest) (*pb.GetThreadResponse, error) {
+	threadRootID := request.GetThreadRootId()
+	if threadRootID == "" {
+		return nil, status.Error(codes.InvalidArgument, "thread_root_id is required")
+	}
 
+	root, err := s.conversationRepo.GetMessage(ctx, threadRootID)
+	if err != nil {
+		return nil, err
+	}
+	// The root is left out as in the history, hidden by the user or sent by a blocked user, and so is its thread
+	blocked, err := s.blockedUsers(ctx, request.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+	if !visibleTo(root, request.GetUserId(), blocked) {
+		return nil, status.Errorf(codes.NotFound, "message %s not found", threadRootID)
+	}
+
+	// Threads are not cached, their replies are read from the database
+	replies, err := s.conversationRepo.GetThreadMessages(ctx, threadRootID, request.GetBefore(), request.GetAfter())
+	if err != nil {
+		return nil, err
+	}
+	olderCursor, newerCursor := pageCursors(replies)
+
+	return &pb.GetThreadResponse{
+		Root:     toProtoMessage(root),
+		Messages: toProtoMessages(replies, request.GetUserId(), blocked),
+		Before:   olderCursor,
+		After:    newerCursor,
+	}, nil
+}
+
+// ListConversations retrieves a page of the conversations of a user, most recently active first.
+func (s *MessageService) ListConversations(ctx context.Context, request *pb.ListConversationsRequest) (*pb.ListConversationsResponse, error) {
+	userID := request.GetUserId()
+	if userID == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	conversations, err := s.inboxRepo.ListConversations(ctx, userID, request.GetBefore())
+	if err != nil {
+		return nil, err
+	}
+
+	// Notification settings are stored apart from the inbox, only for the conversations whose settings the user changed
+	conversationIDs := make([]string, 0, len(conversations))
+	for _, conversation := range conversations {
+		conversationIDs = append(conversationIDs, conversation.ConversationID)
+	}
+	settings, err := s.settingsRepo.GetSettings(ctx, userID, conversationIDs)
+	if err != nil {
+		return nil, err
+	}
+	settingsByConversation := make(map[string]*model.NotificationSettings, len(settings))
+	for _, conversationSettings := range settings {
+		settingsByConversation[conversationSettings.ConversationID] = conversationSettings
+	}
+
+	// Convert to protobuf summaries, the cursor of the next page being the last conversation of this one
+	summaries := make([]*pb.ConversationSummary, 0, len(conversations))
+	var olderCursor string
+	for _, conversation := range conversations {
+		summary := &pb.ConversationSummary{
+			ConversationId: conversation.ConversationID,
+			LastActivityAt: timestamppb.New(conversation.LastActivityAt),
+			UnreadCount:    conversation.UnreadCount,
+			LastRead:       toProtoReceiptPosition(conversation.LastRead),
+		}
+		if conversationSettings, ok := settingsByConversation[conversation.ConversationID]; ok {
+			summary.Notifications = toProtoNotificationSettings(conversationSettings)
+		}
+		if conversation.LastMessage != nil {
+			summary.LastMessage = toProtoMessage(conversation.LastMessage)
+		}
+		summaries = append(summaries, summary)
+		olderCursor = fmt.Sprintf("%s_%s", conversation.LastActivityAt.Format(time.RFC3339Nano), conversation.ConversationID)
+	}
+
+	return &pb.ListConversationsResponse{
+		Conversations: summaries,
+		Before:        olderCursor,
+	}, nil
+}
+
+// pageCursors returns the cursors of the pages older and newer than the given messages, ordered oldest first.
+func pageCursors(messages []*model.Message) (string, string) {
+	if len(messages) == 0 {
+		return "", ""
+	}
+	first := messages[0]
+	last := messages[len(messages)-1]
+	olderCursor := fmt.Sprintf("%s_%s", first.Timestamp.Format(time.RFC3339Nano), first.ID)
+	newerCursor := fmt.Sprintf("%s_%s", last.Timestamp.Format(time.RFC3339Nano), last.ID)
+	return olderCursor, newerCursor
+}
+
+// blockedUsers returns the users blocked by a user, none if no user is given.
+func (s *MessageService) blockedUsers(ctx context.Context, userID string) (map[string]bool, error) {
+	if userID == "" {
+		return nil, nil
+	}
+	blocks, err := s.blockRepo.ListBlocks(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	blocked := make(map[string]bool, len(blocks))
+	for _, block := range blocks {
+		blocked[block.BlockedUserID] = true
+	}
+	return blocked, nil
+}
+
+// toProtoMessages converts messages to protobuf, without those the user deleted for themselves or received from blocked users.
+func toProtoMessages(messages []*model.Message, userID string, blocked map[string]bool) []*pb.Message {
+	protoMessages := make([]*pb.Message, 0, len(messages))
 	for _, msg := range messages {
-		protoMsg := &pb.Message{}
+		if !visibleTo(msg, userID, blocked) {
+			continue
+		}
+		protoMessages = append(protoMessages, toProtoMessage(msg))
+	}
+	return protoMessages
+}
+
+// visibleTo reports whether a message shows in the history of the user, not being hidden by the user nor sent by a blocked user.
+func visibleTo(msg *model.Message, userID string, blocked map[string]bool) bool {
+	if userID != "" && slices.Contains(msg.HiddenFor, userID) {
+		return false
+	}
+	return !blocked[msg.Sender]
+}
+
+// GetMessage retrieves a single message by its ID.
+func (s *MessageService) GetMessage(ctx context.Context, request *pb.GetMessageRequest) (*pb.GetMessageResponse, error) {
+	messageID := request.GetMessageId()
+	if messageID == "" {
+		return nil, status.Error(codes.InvalidArgument, "message_id is required")
+	}
+
+	message, err := s.conversationRepo.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetMessageResponse{
+		Message: toProtoMessage(message),
+	}, nil
+}
+
+// toProtoMessage converts a message to its protobuf representation.
+func toProtoMessage(msg *model.Message) *pb.Message {
+	protoMsg := &pb.Message{}
 
-		// id
-		protoMsg.Id = msg.ID
+	// id
+	protoMsg.Id = msg.ID
 
-		// conversationId
-		protoMsg.ConversationId = msg.ConversationID
+	// conversationId
+	protoMsg.ConversationId = msg.ConversationID
 
-		// sender
-		protoMsg.Sender = msg.Sender
+	// sender
+	protoMsg.Sender = msg.Sender
 
-		// destination
-		protoMsg.Destination = msg.Destination
+	// destination
+	protoMsg.Destination = msg.Destination
 
-		// content
-		protoMsg.Content = msg.Content
+	// content
+	protoMsg.Content = msg.Content
 
-		// timestamp
-		protoMsg.Timestamp = timestamppb.New(msg.Timestamp)
+	// timestamp
+	protoMsg.Timestamp = timestamppb.New(msg.Timestamp)
 
-		conversation = append(conversation, protoMsg)
+	// editedAt, only set for edited messages
+	if !msg.EditedAt.IsZero() {
+		protoMsg.EditedAt = timestamppb.New(msg.EditedAt)
 	}
 
-	return &pb.GetMessagesResponse{
-		Messages: conversation,
+	// deletedAt and deletedBy, only set for messages deleted for everyone
+	if !msg.DeletedAt.IsZero() {
+		protoMsg.DeletedAt = timestamppb.New(msg.DeletedAt)
+		protoMsg.DeletedBy = msg.DeletedBy
+	}
+
+	// reactions, sorted so the summary is stable, without those every user removed
+	for _, reaction := range slices.Sorted(maps.Keys(msg.Reactions)) {
+		if users := msg.Reactions[reaction]; len(users) > 0 {
+			protoMsg.Reactions = append(protoMsg.Reactions, &pb.Reaction{Reaction: reaction, Users: users})
+		}
+	}
+
+	// replyTo and threadRootId, only set for replies
+	protoMsg.ReplyTo = msg.ReplyTo
+	protoMsg.ThreadRootId = msg.ThreadRootID
+
+	// replyCount and lastReplyAt, only set for root messages of threads
+	protoMsg.ReplyCount = msg.ReplyCount
+	if !msg.LastReplyAt.IsZero() {
+		protoMsg.LastReplyAt = timestamppb.New(msg.LastReplyAt)
+	}
+
+	// attachments
+	for _, attachment := range msg.Attachments {
+		protoMsg.Attachments = append(protoMsg.Attachments, &pb.Attachment{
+			Id:       attachment.ID,
+			Name:     attachment.Name,
+			MimeType: attachment.MimeType,
+			Size:     attachment.Size,
+			Checksum: attachment.Checksum,
+			Width:    int32(attachment.Width),
+			Height:   int32(attachment.Height),
+		})
+	}
+
+	// mentions
+	protoMsg.Mentions = msg.Mentions
+
+	return protoMsg
+}
+
+// GetGroup retrieves a group conversation and its members.
+func (s *MessageService) GetGroup(ctx context.Context, request *pb.GetGroupRequest) (*pb.GetGroupResponse, error) {
+	groupID := request.GetGroupId()
+	if groupID == "" {
+		return nil, status.Error(codes.InvalidArgument, "group_id is required")
+	}
+
+	group, err := s.groupRepo.GetGroup(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetGroupResponse{
+		Group: &pb.Group{
+			Id:        group.ID,
+			Name:      group.Name,
+			Members:   group.Members,
+			CreatedBy: group.CreatedBy,
+			CreatedAt: timestamppb.New(group.CreatedAt),
+		},
 	}, nil
 }
+
+// GetReceipts retrieves the latest delivery and read positions of each participant of a conversation.
+func (s *MessageService) GetReceipts(ctx context.Context, request *pb.GetReceiptsRequest) (*pb.GetReceiptsResponse, error) {
+	conversationID := request.GetConversationId()
+	if conversationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "conversation_id is required")
+	}
+
+	receipts, err := s.receiptRepo.GetReceipts(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert to protobuf receipts
+	protoReceipts := make([]*pb.Receipt, 0, len(receipts))
+	for _, receipt := range receipts {
+		protoReceipts = append(protoReceipts, &pb.Receipt{
+			ConversationId: receipt.ConversationID,
+			UserId:         receipt.UserID,
+			Delivered:      toProtoReceiptPosition(receipt.Delivered),
+			Read:           toProtoReceiptPosition(receipt.Read),
+		})
+	}
+
+	return &pb.GetReceiptsResponse{
+		Receipts: protoReceipts,
+	}, nil
+}
+
+// ListBlocks retrieves the users a user blocked.
+func (s *MessageService) ListBlocks(ctx context.Context, request *pb.ListBlocksRequest) (*pb.ListBlocksResponse, error) {
+	userID := request.GetUserId()
+	if userID == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	blocks, err := s.blockRepo.ListBlocks(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert to protobuf blocks
+	protoBlocks := make([]*pb.Block, 0, len(blocks))
+	for _, block := range blocks {
+		protoBlocks = append(protoBlocks, &pb.Block{
+			UserId:        block.UserID,
+			BlockedUserId: block.BlockedUserID,
+			BlockedAt:     timestamppb.New(block.UpdatedAt),
+		})
+	}
+
+	return &pb.ListBlocksResponse{
+		Blocks: protoBlocks,
+	}, nil
+}
+
+// ListBlockers retrieves the users, among the requested ones, who blocked a user.
+func (s *MessageService) ListBlockers(ctx context.Context, request *pb.ListBlockersRequest) (*pb.ListBlockersResponse, error) {
+	userID := request.GetUserId()
+	if userID == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	blockers, err := s.blockRepo.ListBlockers(ctx, userID, request.GetUsers())
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.ListBlockersResponse{
+		Users: blockers,
+	}, nil
+}
+
+// GetNotificationSettings retrieves the notification settings of a user for a conversation.
+func (s *MessageService) GetNotificationSettings(ctx context.Context, request *pb.GetNotificationSettingsRequest) (*pb.GetNotificationSettingsResponse, error) {
+	userID := request.GetUserId()
+	conversationID := request.GetConversationId()
+	if userID == "" || conversationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id and conversation_id are required")
+	}
+
+	settings, err := s.settingsRepo.GetSettings(ctx, userID, []string{conversationID})
+	if err != nil {
+		return nil, err
+	}
+
+	// Users are notified of all messages until they change the settings
+	conversationSettings := &model.NotificationSettings{UserID: userID, ConversationID: conversationID, Level: model.NotifyAll}
+	if len(settings) > 0 {
+		conversationSettings = settings[0]
+	}
+
+	return &pb.GetNotificationSettingsResponse{
+		Settings: toProtoNotificationSettings(conversationSettings),
+	}, nil
+}
+
+// toProtoNotificationSettings converts notification settings to protobuf, keeping unset times nil.
+func toProtoNotificationSettings(settings *model.NotificationSettings) *pb.NotificationSettings {
+	protoSettings := &pb.NotificationSettings{
+		UserId:         settings.UserID,
+		ConversationId: settings.ConversationID,
+		Level:          settings.Level,
+	}
+	if !settings.MutedUntil.IsZero() {
+		protoSettings.MutedUntil = timestamppb.New(settings.MutedUntil)
+	}
+	if !settings.UpdatedAt.IsZero() {
+		protoSettings.UpdatedAt = timestamppb.New(settings.UpdatedAt)
+	}
+	return protoSettings
+}
+
+// toProtoReceiptPosition converts a receipt position to protobuf, keeping missing positions nil.
+func toProtoReceiptPosition(position *model.ReceiptPosition) *pb.ReceiptPosition {
+	if position == nil {
+		return nil
+	}
+	return &pb.ReceiptPosition{
+		MessageId:        position.MessageID,
+		MessageTimestamp: timestamppb.New(position.MessageTimestamp),
+		ReportedAt:       timestamppb.New(position.ReportedAt),
+	}
+}
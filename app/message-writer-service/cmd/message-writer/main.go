@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/config"
 	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/db"
@@ -17,6 +18,7 @@ import (
 	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/worker"
 	"github.com/nsmsb/darda-chat/app/message-writer-service/pkg/logger"
 	"github.com/nsmsb/darda-chat/app/message-writer-service/pkg/rabbitmq"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
 
@@ -38,6 +40,18 @@ func main() {
 		}
 	}()
 
+	// Connecting to Redis, used to push notifications to the users' devices
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     config.RedisAddr,
+		Password: config.RedisPass,
+		DB:       config.RedisDB,
+	})
+	defer func() {
+		if err := redisClient.Close(); err != nil {
+			logger.Error("Error closing Redis connection", zap.Error(err))
+		}
+	}()
+
 	// Preparing rabbitMQ connection
 	conn := rabbitmq.Conn()
 	defer func() {
@@ -56,10 +70,39 @@ func main() {
 	// Preparing repositories
 	messageRepository := repository.NewMongoMessageRepository(dbClient, config.MongoDBName, config.MongoCollectionName)
 	outboxRepository := repository.NewMongoOutboxMessageRepository(dbClient, config.MongoDBName, fmt.Sprintf("%s_outbox", config.MongoCollectionName))
+	groupRepository := repository.NewMongoGroupRepository(dbClient, config.MongoDBName, config.MongoGroupCollection)
+	receiptRepository := repository.NewMongoReceiptRepository(dbClient, config.MongoDBName, config.MongoReceiptCollection)
+	inboxRepository := repository.NewMongoInboxRepository(dbClient, config.MongoDBName, config.MongoInboxCollection)
+	blockRepository := repository.NewMongoBlockRepository(dbClient, config.MongoDBName, config.MongoBlockCollection)
+	settingsRepository := repository.NewMongoNotificationSettingsRepository(dbClient, config.MongoDBName, config.MongoNotifyCollection)
+
+	// Creating the indexes used by the reader to page through conversations, threads and inboxes, and to look up blocks
+	indexCtx, indexCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	if err := messageRepository.CreateIndexes(indexCtx); err != nil {
+		logger.Fatal("Failed to create message indexes", zap.Error(err))
+	}
+	if err := inboxRepository.CreateIndexes(indexCtx); err != nil {
+		logger.Fatal("Failed to create inbox indexes", zap.Error(err))
+	}
+	if err := blockRepository.CreateIndexes(indexCtx); err != nil {
+		logger.Fatal("Failed to create block indexes", zap.Error(err))
+	}
+	indexCancel()
 
 	// Initializing Message consumer Service
 	messageSource := source.NewRabbitMQSource[model.Event](channel, config.MsgQueue)
-	processor := processor.NewMessageProcessor(messageRepository, outboxRepository, dbClient)
+	var notifier service.Notifier
+	switch config.RoutingMode {
+	case "user":
+		notifier = service.NewRedisNotifier(redisClient)
+	case "replica":
+		notifier = service.NewRedisRoutedNotifier(redisClient)
+	case "stream":
+		notifier = service.NewRedisStreamNotifier(redisClient, config.StreamMaxLen, config.StreamTTL)
+	default:
+		logger.Fatal("Unsupported routing mode", zap.String("mode", config.RoutingMode))
+	}
+	processor := processor.NewMessageProcessor(messageRepository, outboxRepository, groupRepository, receiptRepository, inboxRepository, blockRepository, settingsRepository, notifier, dbClient)
 	logger.Info("Initializing message consumer service")
 	messageProcessingWorkerPool := worker.NewWorkerPool(messageSource, processor, config.ConsumerPoolSize)
 
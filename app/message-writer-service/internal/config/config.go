@@ -4,6 +4,7 @@ import (
 	"os"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/nsmsb/darda-chat/app/message-writer-service/pkg/logger"
 	"go.uber.org/zap"
@@ -11,17 +12,28 @@ import (
 
 // Config holds the configuration values for the application.
 type Config struct {
-	AMQPUser            string
-	AMQPPass            string
-	AMQPHost            string
-	MsgQueue            string
-	MongoDBName         string
-	MongoCollectionName string
-	MongoAddr           string
-	MongoUser           string
-	MongoPass           string
-	MongoTimeout        string
-	ConsumerPoolSize    int
+	AMQPUser               string
+	AMQPPass               string
+	AMQPHost               string
+	MsgQueue               string
+	MongoDBName            string
+	MongoCollectionName    string
+	MongoGroupCollection   string
+	MongoReceiptCollection string
+	MongoInboxCollection   string
+	MongoBlockCollection   string
+	MongoNotifyCollection  string
+	MongoAddr              string
+	MongoUser              string
+	MongoPass              string
+	MongoTimeout           string
+	RedisAddr              string
+	RedisPass              string
+	RedisDB                int
+	RoutingMode            string        // Routing mode of the chat-service, "user", "replica" or "stream", deciding where notifications are published
+	StreamMaxLen           int64         // Approximate number of entries kept in the stream of a user, in "stream" routing mode
+	StreamTTL              time.Duration // Duration the stream of a user is kept after its last entry, in "stream" routing mode
+	ConsumerPoolSize       int
 }
 
 var (
@@ -41,19 +53,54 @@ func Get() *Config {
 		}
 	}
 
+	var redisDB = 0 // default value
+	redisDBEnv := getEnv("REDIS_DB", "0")
+	if val, err := strconv.Atoi(redisDBEnv); err == nil {
+		redisDB = val
+	} else {
+		logger.Get().Error("Invalid REDIS_DB, using default", zap.String("value", redisDBEnv), zap.Error(err))
+	}
+
+	var streamMaxLen int64 = 1000 // default value
+	streamMaxLenEnv := getEnv("STREAM_MAX_LEN", "1000")
+	if val, err := strconv.ParseInt(streamMaxLenEnv, 10, 64); err == nil {
+		streamMaxLen = val
+	} else {
+		logger.Get().Error("Invalid STREAM_MAX_LEN, using default", zap.String("value", streamMaxLenEnv), zap.Error(err))
+	}
+
+	var streamTTL = 5 * time.Minute // default value
+	streamTTLEnv := getEnv("STREAM_TTL", "5m")
+	if val, err := time.ParseDuration(streamTTLEnv); err == nil {
+		streamTTL = val
+	} else {
+		logger.Get().Error("Invalid STREAM_TTL, using default", zap.String("value", streamTTLEnv), zap.Error(err))
+	}
+
 	once.Do(func() {
 		instance = &Config{
-			ConsumerPoolSize:    consumerPoolSize,
-			AMQPUser:            getEnv("AMQP_USER", ""),
-			AMQPPass:            getEnv("AMQP_PASS", ""),
-			AMQPHost:            getEnv("AMQP_HOST", ""),
-			MsgQueue:            getEnv("MSG_QUEUE", "messages"),
-			MongoDBName:         getEnv("MONGO_DB_NAME", "darda_chat"),
-			MongoCollectionName: getEnv("MONGO_COLLECTION_NAME", "messages"),
-			MongoAddr:           getEnv("MONGO_ADDR", "mongodb://localhost:27017"),
-			MongoTimeout:        getEnv("MONGO_TIMEOUT", "10s"),
-			MongoUser:           getEnv("MONGO_USER", "root"),
-			MongoPass:           getEnv("MONGO_PASS", ""),
+			ConsumerPoolSize:       consumerPoolSize,
+			AMQPUser:               getEnv("AMQP_USER", ""),
+			AMQPPass:               getEnv("AMQP_PASS", ""),
+			AMQPHost:               getEnv("AMQP_HOST", ""),
+			MsgQueue:               getEnv("MSG_QUEUE", "messages"),
+			MongoDBName:            getEnv("MONGO_DB_NAME", "darda_chat"),
+			MongoCollectionName:    getEnv("MONGO_COLLECTION_NAME", "messages"),
+			MongoGroupCollection:   getEnv("MONGO_GROUP_COLLECTION_NAME", "groups"),
+			MongoReceiptCollection: getEnv("MONGO_RECEIPT_COLLECTION_NAME", "receipts"),
+			MongoInboxCollection:   getEnv("MONGO_INBOX_COLLECTION_NAME", "inbox"),
+			MongoBlockCollection:   getEnv("MONGO_BLOCK_COLLECTION_NAME", "blocks"),
+			MongoNotifyCollection:  getEnv("MONGO_NOTIFICATION_COLLECTION_NAME", "notification_settings"),
+			MongoAddr:              getEnv("MONGO_ADDR", "mongodb://localhost:27017"),
+			MongoTimeout:           getEnv("MONGO_TIMEOUT", "10s"),
+			MongoUser:              getEnv("MONGO_USER", "root"),
+			MongoPass:              getEnv("MONGO_PASS", ""),
+			RedisAddr:              getEnv("REDIS_ADDR", "localhost:6379"),
+			RedisPass:              getEnv("REDIS_PASS", ""),
+			RedisDB:                redisDB,
+			RoutingMode:            getEnv("ROUTING_MODE", "user"),
+			StreamMaxLen:           streamMaxLen,
+			StreamTTL:              streamTTL,
 		}
 	})
 	return instance
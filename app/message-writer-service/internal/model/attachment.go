@@ -0,0 +1,12 @@
+package model
+
+// Represents the metadata of a file attached to a message
+type Attachment struct {
+	ID       string `json:"id" bson:"id"`
+	Name     string `json:"name,omitempty" bson:"name,omitempty"`
+	MimeType string `json:"mime_type" bson:"mimeType"`
+	Size     int64  `json:"size" bson:"size"`
+	Checksum string `json:"checksum" bson:"checksum"`                 // Hex encoded SHA-256 of the content
+	Width    int    `json:"width,omitempty" bson:"width,omitempty"`   // Dimensions of images
+	Height   int    `json:"height,omitempty" bson:"height,omitempty"` // Dimensions of images
+}
@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// Represents a user blocking or unblocking another user, only the latest change being kept
+type Block struct {
+	UserID        string    `json:"user_id" bson:"userId"`                // User who blocks
+	BlockedUserID string    `json:"blocked_user_id" bson:"blockedUserId"` // User being blocked
+	Blocked       bool      `json:"blocked" bson:"blocked"`               // False once unblocked
+	UpdatedAt     time.Time `json:"updated_at" bson:"updatedAt"`
+}
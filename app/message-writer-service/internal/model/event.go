@@ -7,13 +7,19 @@ import (
 
 const (
 	// Event types
-	EventTypeMessage      = "Message"
-	EventTypeMessageEvent = "MessageEvent"
+	EventTypeMessage              = "Message"
+	EventTypeMessageEvent         = "MessageEvent"
+	EventTypeGroup                = "Group"
+	EventTypeMessageEdit          = "MessageEdit"
+	EventTypeMessageDelete        = "MessageDelete"
+	EventTypeBlock                = "Block"
+	EventTypeNotificationSettings = "NotificationSettings"
+	EventTypeUnreadCount          = "UnreadCount" // Pushed to the devices of users, never consumed
 )
 
 // Represents a generic event wrapper
 type Event struct {
-	Type      string          `json:"type"`      // "Message", "MessageEvent"
+	Type      string          `json:"type"`      // "Message", "MessageEvent", "Group", "MessageEdit", "MessageDelete", "Block", "NotificationSettings"
 	EventID   string          `json:"event_id"`  // Unique ID for the event
 	Timestamp time.Time       `json:"timestamp"` // Timestamp when the event was created
 	Content   json.RawMessage `json:"content"`   // Raw JSON, to decode later depending on Type
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// Represents a group conversation and its members
+type Group struct {
+	ID        string    `json:"id" bson:"_id"`
+	Name      string    `json:"name" bson:"name"`
+	Members   []string  `json:"members" bson:"members"`
+	CreatedBy string    `json:"created_by" bson:"createdBy"`
+	CreatedAt time.Time `json:"created_at" bson:"createdAt"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+const (
+	// Message deletion scopes
+	DeleteForEveryone = "everyone"
+	DeleteForMe       = "me"
+)
+
+// Represents the deletion of a message, for everyone by its sender or a group admin, or for the deleting user only
+type MessageDelete struct {
+	MessageID      string    `json:"message_id"`
+	ConversationID string    `json:"conversation_id"`
+	Scope          string    `json:"scope"`   // "everyone", "me"
+	UserID         string    `json:"user_id"` // User who deleted the message
+	DeletedAt      time.Time `json:"deleted_at"`
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// Represents the new content of a message, prior contents are kept as revisions of the message
+type MessageEdit struct {
+	MessageID      string    `json:"message_id"`
+	ConversationID string    `json:"conversation_id"`
+	Sender         string    `json:"sender"` // Only the sender of the message can edit it
+	Content        string    `json:"content"`
+	EditedAt       time.Time `json:"edited_at"`
+}
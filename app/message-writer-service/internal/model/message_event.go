@@ -2,8 +2,22 @@ package model
 
 import "time"
 
+const (
+	// Message event types
+	MessageEventDelivered       = "delivered"
+	MessageEventRead            = "read"
+	MessageEventReactionAdded   = "reaction_added"
+	MessageEventReactionRemoved = "reaction_removed"
+)
+
 // Represents message-related events (e.g. delivered, read, typing)
 type MessageEvent struct {
-	ConversationID string    `json:"conversation_id"`
-	Timestamp      time.Time `json:"timestamp"`
+	Type             string    `json:"type"`              // "delivered", "read", "reaction_added", "reaction_removed"
+	MessageID        string    `json:"message_id"`        // Message the event refers to
+	MessageTimestamp time.Time `json:"message_timestamp"` // Timestamp of the message, used to order positions
+	ConversationID   string    `json:"conversation_id"`
+	Sender           string    `json:"sender"`             // Sender of the message
+	UserID           string    `json:"user_id"`            // User who reported the event
+	Reaction         string    `json:"reaction,omitempty"` // Only for reaction events
+	Timestamp        time.Time `json:"timestamp"`
 }
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+const (
+	// Notification levels of a conversation
+	NotifyAll      = "all"
+	NotifyMentions = "mentions" // Only messages mentioning the user
+	NotifyMuted    = "muted"    // No message until MutedUntil, or until changed if unset
+)
+
+// Represents how a user is notified of the messages of a conversation, only the latest change being kept
+type NotificationSettings struct {
+	UserID         string    `json:"user_id" bson:"userId"`
+	ConversationID string    `json:"conversation_id" bson:"conversationId"`
+	Level          string    `json:"level" bson:"level"`
+	MutedUntil     time.Time `json:"muted_until,omitzero" bson:"mutedUntil,omitempty"` // Only for the muted level, notifying of all messages once past
+	UpdatedAt      time.Time `json:"updated_at" bson:"updatedAt"`
+}
+
+// Notifies reports whether the user is notified of a message of the conversation at the given time.
+func (s NotificationSettings) Notifies(message Message, now time.Time) bool {
+	switch s.Level {
+	case NotifyMuted:
+		return !s.MutedUntil.IsZero() && !now.Before(s.MutedUntil)
+	case NotifyMentions:
+		return slices.Contains(message.Mentions, s.UserID)
+	default:
+		return true
+	}
+}
@@ -0,0 +1,8 @@
+package model
+
+// Represents the number of messages of a conversation a user didn't read, pushed to the user's devices when it changes
+type UnreadCount struct {
+	UserID         string `json:"-" bson:"userId"`
+	ConversationID string `json:"conversation_id" bson:"conversationId"`
+	UnreadCount    int64  `json:"unread_count" bson:"unreadCount"`
+}
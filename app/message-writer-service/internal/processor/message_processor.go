@@ -3,45 +3,521 @@ package processor
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/model"
 	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/repository"
+	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/service"
+	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/utils"
+	"github.com/nsmsb/darda-chat/app/message-writer-service/pkg/logger"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
 )
 
 type MessageProcessor struct {
 	messageRepository       repository.MessageRepository
 	outboxMessageRepository repository.OutboxMessageRepository
+	groupRepository         repository.GroupRepository
+	receiptRepository       repository.ReceiptRepository
+	inboxRepository         repository.InboxRepository
+	blockRepository         repository.BlockRepository
+	settingsRepository      repository.NotificationSettingsRepository
+	notifier                service.Notifier
 	client                  *mongo.Client
 }
 
 // NewMessageProcessor creates a new MessageProcessor instance.
-func NewMessageProcessor(messageRepository repository.MessageRepository, outboxMessageRepository repository.OutboxMessageRepository, client *mongo.Client) *MessageProcessor {
+func NewMessageProcessor(messageRepository repository.MessageRepository, outboxMessageRepository repository.OutboxMessageRepository, groupRepository repository.GroupRepository, receiptRepository repository.ReceiptRepository, inboxRepository repository.InboxRepository, blockRepository repository.BlockRepository, settingsRepository repository.NotificationSettingsRepository, notifier service.Notifier, client *mongo.Client) *MessageProcessor {
 	return &MessageProcessor{
 		messageRepository:       messageRepository,
 		outboxMessageRepository: outboxMessageRepository,
+		groupRepository:         groupRepository,
+		receiptRepository:       receiptRepository,
+		inboxRepository:         inboxRepository,
+		blockRepository:         blockRepository,
+		settingsRepository:      settingsRepository,
+		notifier:                notifier,
 		client:                  client,
 	}
 }
 
 // Process processes a message event and writes it to the database
 func (h *MessageProcessor) Process(ctx context.Context, event *model.Event) error {
-	if event.Type == model.EventTypeMessage {
+	switch event.Type {
+	case model.EventTypeMessage:
 		// Adding message to message and outbox collections
 		var msg model.Message
 		if err := json.Unmarshal(event.Content, &msg); err != nil {
 			return fmt.Errorf("unmarshal event content error: %w", err)
 		}
 
+		// Only participants of the conversation can send messages to it
+		participants, err := h.checkParticipants(ctx, msg.ConversationID, msg.Sender, msg.Destination)
+		if err != nil {
+			return err
+		}
+		for _, mention := range msg.Mentions {
+			if !slices.Contains(participants, mention) {
+				return fmt.Errorf("%w: mentioned user %s is not a participant of conversation %s", ErrRejected, mention, msg.ConversationID)
+			}
+		}
+		if err := h.checkThreadRoot(ctx, msg); err != nil {
+			return err
+		}
+
+		// Recipients who block the sender neither count the message as unread nor are notified of it
+		recipients := slices.DeleteFunc(slices.Clone(participants), func(user string) bool { return user == msg.Sender })
+		blockers, err := h.blockRepository.ListBlockers(ctx, msg.Sender, recipients)
+		if err != nil {
+			return err
+		}
+
 		// Insert message with outbox pattern
-		return h.insertMessageWithOutbox(ctx, msg)
+		err = h.insertMessageWithOutbox(ctx, msg, participants, blockers)
+		if mongo.IsDuplicateKeyError(err) {
+			// Retried sends and redeliveries reuse the message ID
+			return h.checkDuplicateMessage(ctx, msg)
+		}
+		if err != nil {
+			return err
+		}
+
+		// The unread counts of the recipients went up, only those notified of the message are told
+		recipients = slices.DeleteFunc(recipients, func(user string) bool { return slices.Contains(blockers, user) })
+		h.notifyUnreadCounts(ctx, msg.ConversationID, h.notifiedRecipients(ctx, msg, recipients))
+		return nil
+
+	case model.EventTypeMessageEdit:
+		var edit model.MessageEdit
+		if err := json.Unmarshal(event.Content, &edit); err != nil {
+			return fmt.Errorf("unmarshal event content error: %w", err)
+		}
+		if _, err := h.checkParticipants(ctx, edit.ConversationID, edit.Sender); err != nil {
+			return err
+		}
+		return h.editMessageWithOutbox(ctx, edit)
+
+	case model.EventTypeMessageDelete:
+		var del model.MessageDelete
+		if err := json.Unmarshal(event.Content, &del); err != nil {
+			return fmt.Errorf("unmarshal event content error: %w", err)
+		}
+		return h.deleteMessageWithOutbox(ctx, del)
+
+	case model.EventTypeGroup:
+		// Storing the group with its member list
+		var group model.Group
+		if err := json.Unmarshal(event.Content, &group); err != nil {
+			return fmt.Errorf("unmarshal event content error: %w", err)
+		}
+		if err := h.groupRepository.WriteGroup(ctx, group); err != nil {
+			return err
+		}
+		// The group shows in the inbox of its members before its first message
+		return h.inboxRepository.AddConversation(ctx, group.ID, group.Members, group.CreatedAt)
+
+	case model.EventTypeBlock:
+		// Storing the latest block or unblock between two users
+		var block model.Block
+		if err := json.Unmarshal(event.Content, &block); err != nil {
+			return fmt.Errorf("unmarshal event content error: %w", err)
+		}
+		if block.UserID == "" || block.BlockedUserID == "" || block.UserID == block.BlockedUserID {
+			return fmt.Errorf("%w: invalid block of %q by %q", ErrRejected, block.BlockedUserID, block.UserID)
+		}
+		return h.blockRepository.WriteBlock(ctx, block)
+
+	case model.EventTypeNotificationSettings:
+		// Storing the latest notification settings of a user for a conversation
+		var settings model.NotificationSettings
+		if err := json.Unmarshal(event.Content, &settings); err != nil {
+			return fmt.Errorf("unmarshal event content error: %w", err)
+		}
+		switch settings.Level {
+		case model.NotifyAll, model.NotifyMentions, model.NotifyMuted:
+		default:
+			return fmt.Errorf("%w: unsupported notification level %q", ErrRejected, settings.Level)
+		}
+		if _, err := h.checkParticipants(ctx, settings.ConversationID, settings.UserID); err != nil {
+			return err
+		}
+		return h.settingsRepository.WriteSettings(ctx, settings)
+
+	case model.EventTypeMessageEvent:
+		var msgEvent model.MessageEvent
+		if err := json.Unmarshal(event.Content, &msgEvent); err != nil {
+			return fmt.Errorf("unmarshal event content error: %w", err)
+		}
+		// Only delivery and read receipts, and reactions are persisted
+		switch msgEvent.Type {
+		case model.MessageEventDelivered, model.MessageEventRead:
+			if err := h.checkReceiptMessage(ctx, &msgEvent); err != nil {
+				return err
+			}
+			if _, err := h.checkParticipants(ctx, msgEvent.ConversationID, msgEvent.UserID, msgEvent.Sender); err != nil {
+				return err
+			}
+			if err := h.receiptRepository.WriteReceipt(ctx, msgEvent); err != nil {
+				return err
+			}
+			if msgEvent.Type == model.MessageEventRead {
+				return h.markRead(ctx, msgEvent)
+			}
+			return nil
+		case model.MessageEventReactionAdded, model.MessageEventReactionRemoved:
+			return h.reactWithOutbox(ctx, msgEvent)
+		}
 	}
 	return nil
 }
 
+// checkParticipants makes sure the given users take part in the conversation, empty users are ignored,
+// and returns the participants of the conversation. Events failing the check are rejected, so spoofed events can't be written.
+func (h *MessageProcessor) checkParticipants(ctx context.Context, conversationID string, users ...string) ([]string, error) {
+	if conversationID == "" || users[0] == "" {
+		return nil, fmt.Errorf("%w: missing conversation or sender", ErrRejected)
+	}
+
+	var members []string
+	if utils.IsGroupConvId(conversationID) {
+		group, err := h.groupRepository.GetGroup(ctx, conversationID)
+		if errors.Is(err, repository.ErrGroupNotFound) {
+			return nil, fmt.Errorf("%w: unknown group %s", ErrRejected, conversationID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		members = group.Members
+	} else {
+		members = utils.ParseConvId(conversationID)
+		if len(members) != 2 {
+			return nil, fmt.Errorf("%w: invalid conversation %s", ErrRejected, conversationID)
+		}
+	}
+
+	for _, user := range users {
+		if user != "" && !slices.Contains(members, user) {
+			return nil, fmt.Errorf("%w: user %s is not a participant of conversation %s", ErrRejected, user, conversationID)
+		}
+	}
+	return members, nil
+}
+
+// checkDuplicateMessage accepts a message whose ID is already stored if it is the same message, already written and dispatched.
+// A different message reusing the ID is rejected.
+func (h *MessageProcessor) checkDuplicateMessage(ctx context.Context, msg model.Message) error {
+	stored, err := h.messageRepository.GetMessage(ctx, msg.ID)
+	if err != nil {
+		return err
+	}
+	// Timestamps are not compared, the chat-service stamps each retry anew
+	if stored.ConversationID != msg.ConversationID || stored.Sender != msg.Sender || stored.Destination != msg.Destination || stored.Content != msg.Content ||
+		stored.ReplyTo != msg.ReplyTo || stored.ThreadRootID != msg.ThreadRootID || !slices.Equal(stored.Attachments, msg.Attachments) ||
+		!slices.Equal(stored.Mentions, msg.Mentions) {
+		return fmt.Errorf("%w: message ID %s already used by another message", ErrRejected, msg.ID)
+	}
+	return nil
+}
+
+// checkThreadRoot makes sure a reply in a thread refers to a root message of the same conversation, outside of any thread itself.
+func (h *MessageProcessor) checkThreadRoot(ctx context.Context, msg model.Message) error {
+	if msg.ThreadRootID == "" {
+		return nil
+	}
+	root, err := h.getStoredMessage(ctx, msg.ThreadRootID)
+	if err != nil {
+		return err
+	}
+	if root.ConversationID != msg.ConversationID || root.ThreadRootID != "" {
+		return fmt.Errorf("%w: message %s can't be the root of a thread of conversation %s", ErrRejected, msg.ThreadRootID, msg.ConversationID)
+	}
+	return nil
+}
+
+// checkReceiptMessage makes sure the message of a receipt exists in its conversation, and positions the receipt
+// on the stored message, so a receipt can't move a read position past messages that don't exist.
+func (h *MessageProcessor) checkReceiptMessage(ctx context.Context, event *model.MessageEvent) error {
+	if event.MessageID == "" {
+		return fmt.Errorf("%w: missing message", ErrRejected)
+	}
+	// Receipts are only sent for messages read back from the reader, so they are written already
+	stored, err := h.messageRepository.GetMessage(ctx, event.MessageID)
+	if errors.Is(err, repository.ErrMessageNotFound) {
+		return fmt.Errorf("%w: unknown message %s", ErrRejected, event.MessageID)
+	}
+	if err != nil {
+		return err
+	}
+	if stored.ConversationID != event.ConversationID {
+		return fmt.Errorf("%w: message %s is not in conversation %s", ErrRejected, event.MessageID, event.ConversationID)
+	}
+	event.Sender = stored.Sender
+	event.MessageTimestamp = stored.Timestamp
+	return nil
+}
+
+// editMessageWithOutbox applies an edit to a message and creates an outbox event with the new version in a transaction.
+func (h *MessageProcessor) editMessageWithOutbox(ctx context.Context, edit model.MessageEdit) error {
+	err := h.updateMessageWithOutbox(ctx, func(sessionCtx mongo.SessionContext) (model.Message, error) {
+		return h.messageRepository.EditMessage(sessionCtx, edit)
+	})
+	if !errors.Is(err, repository.ErrMessageNotModified) {
+		return err
+	}
+
+	// Finding out why the edit didn't apply
+	stored, err := h.getStoredMessage(ctx, edit.MessageID)
+	if err != nil {
+		return err
+	}
+	if stored.Sender != edit.Sender || stored.ConversationID != edit.ConversationID {
+		return fmt.Errorf("%w: user %s can't edit message %s", ErrRejected, edit.Sender, edit.MessageID)
+	}
+	// Already applied, superseded by a newer edit or deleted
+	return nil
+}
+
+// deleteMessageWithOutbox deletes a message for everyone or for the deleting user only,
+// and creates an outbox event with the new version in a transaction.
+func (h *MessageProcessor) deleteMessageWithOutbox(ctx context.Context, del model.MessageDelete) error {
+	if _, err := h.checkParticipants(ctx, del.ConversationID, del.UserID); err != nil {
+		return err
+	}
+
+	stored, err := h.getStoredMessage(ctx, del.MessageID)
+	if err != nil {
+		return err
+	}
+	if stored.ConversationID != del.ConversationID {
+		return fmt.Errorf("%w: message %s is not part of conversation %s", ErrRejected, del.MessageID, del.ConversationID)
+	}
+
+	var update func(sessionCtx mongo.SessionContext) (model.Message, error)
+	switch del.Scope {
+	case model.DeleteForEveryone:
+		// Only the sender or an admin of the group can delete a message for everyone
+		if stored.Sender != del.UserID {
+			admin, err := h.isGroupAdmin(ctx, del.ConversationID, del.UserID)
+			if err != nil {
+				return err
+			}
+			if !admin {
+				return fmt.Errorf("%w: user %s can't delete message %s for everyone", ErrRejected, del.UserID, del.MessageID)
+			}
+		}
+		update = func(sessionCtx mongo.SessionContext) (model.Message, error) {
+			message, err := h.messageRepository.DeleteMessage(sessionCtx, del)
+			if err != nil || message.ThreadRootID == "" {
+				return message, err
+			}
+			// Uncount the reply from the root message of its thread, unless it isn't counted
+			root, err := h.messageRepository.RemoveReply(sessionCtx, message)
+			if errors.Is(err, repository.ErrMessageNotModified) {
+				return message, nil
+			}
+			if err != nil {
+				return model.Message{}, fmt.Errorf("remove reply error: %w", err)
+			}
+			if err := h.outboxMessageRepository.WriteOutboxMessage(sessionCtx, root); err != nil {
+				return model.Message{}, fmt.Errorf("insert outbox event error: %w", err)
+			}
+			return message, nil
+		}
+	case model.DeleteForMe:
+		update = func(sessionCtx mongo.SessionContext) (model.Message, error) {
+			return h.messageRepository.HideMessage(sessionCtx, del)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported deletion scope %s", ErrRejected, del.Scope)
+	}
+
+	err = h.updateMessageWithOutbox(ctx, update)
+	if errors.Is(err, repository.ErrMessageNotModified) {
+		// Already deleted
+		return nil
+	}
+	return err
+}
+
+// reactWithOutbox adds or removes the reaction of a user to a message, and creates an outbox event with the new version in a transaction.
+func (h *MessageProcessor) reactWithOutbox(ctx context.Context, event model.MessageEvent) error {
+	if _, err := h.checkParticipants(ctx, event.ConversationID, event.UserID); err != nil {
+		return err
+	}
+	// Reactions are used as field names
+	if !validReaction(event.Reaction) {
+		return fmt.Errorf("%w: invalid reaction %q", ErrRejected, event.Reaction)
+	}
+
+	err := h.updateMessageWithOutbox(ctx, func(sessionCtx mongo.SessionContext) (model.Message, error) {
+		if event.Type == model.MessageEventReactionAdded {
+			return h.messageRepository.AddReaction(sessionCtx, event)
+		}
+		return h.messageRepository.RemoveReaction(sessionCtx, event)
+	})
+	if !errors.Is(err, repository.ErrMessageNotModified) {
+		return err
+	}
+
+	// Already applied, unless the message is not written yet
+	stored, err := h.getStoredMessage(ctx, event.MessageID)
+	if err != nil {
+		return err
+	}
+	if stored.ConversationID != event.ConversationID {
+		return fmt.Errorf("%w: message %s is not part of conversation %s", ErrRejected, event.MessageID, event.ConversationID)
+	}
+	return nil
+}
+
+// validReaction reports whether a reaction can be stored, as a short string usable as a field name.
+func validReaction(reaction string) bool {
+	return reaction != "" && len(reaction) <= 64 && !strings.ContainsAny(reaction, ".$")
+}
+
+// getStoredMessage returns a message a change refers to, failing with a retryable error if it is not written yet.
+func (h *MessageProcessor) getStoredMessage(ctx context.Context, messageID string) (model.Message, error) {
+	stored, err := h.messageRepository.GetMessage(ctx, messageID)
+	if errors.Is(err, repository.ErrMessageNotFound) {
+		// The message may not be written yet, retrying later
+		return model.Message{}, fmt.Errorf("changed message %s not found", messageID)
+	}
+	return stored, err
+}
+
+// isGroupAdmin reports whether the user administrates the group conversation, the creator of a group being its admin.
+func (h *MessageProcessor) isGroupAdmin(ctx context.Context, conversationID string, userID string) (bool, error) {
+	if !utils.IsGroupConvId(conversationID) {
+		return false, nil
+	}
+	group, err := h.groupRepository.GetGroup(ctx, conversationID)
+	if err != nil {
+		return false, err
+	}
+	return group.CreatedBy == userID, nil
+}
+
+// updateMessageWithOutbox applies a change to a message and creates an outbox event with its new version in a transaction.
+// ErrMessageNotModified is returned as is when the change doesn't apply.
+func (h *MessageProcessor) updateMessageWithOutbox(ctx context.Context, update func(sessionCtx mongo.SessionContext) (model.Message, error)) error {
+	session, err := h.client.StartSession()
+	if err != nil {
+		return fmt.Errorf("start session error: %w", err)
+	}
+	defer session.EndSession(ctx)
+
+	callback := func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		message, err := update(sessionCtx)
+		if err != nil {
+			return nil, err
+		}
+		if err := h.outboxMessageRepository.WriteOutboxMessage(sessionCtx, message); err != nil {
+			return nil, fmt.Errorf("insert outbox event error: %w", err)
+		}
+		// Inboxes showing the message as the last one of the conversation show its new version
+		if err := h.inboxRepository.UpdateMessage(sessionCtx, message); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	_, err = session.WithTransaction(ctx, callback)
+	if errors.Is(err, repository.ErrMessageNotModified) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("transaction error: %w", err)
+	}
+	return nil
+}
+
+// markRead moves the read position of the user in the inbox and recounts the messages left unread after it in a transaction,
+// so messages written meanwhile are counted once. Messages of the users the user blocks are not counted.
+// The new count is pushed to the user's devices.
+func (h *MessageProcessor) markRead(ctx context.Context, event model.MessageEvent) error {
+	blocked, err := h.blockRepository.ListBlocked(ctx, event.UserID)
+	if err != nil {
+		return err
+	}
+
+	session, err := h.client.StartSession()
+	if err != nil {
+		return fmt.Errorf("start session error: %w", err)
+	}
+	defer session.EndSession(ctx)
+
+	read := model.ReceiptPosition{
+		MessageID:        event.MessageID,
+		MessageTimestamp: event.MessageTimestamp,
+		ReportedAt:       event.Timestamp,
+	}
+	callback := func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		unread, err := h.messageRepository.CountUnread(sessionCtx, event.ConversationID, event.UserID, &read, blocked)
+		if err != nil {
+			return false, err
+		}
+		return h.inboxRepository.MarkRead(sessionCtx, event.UserID, event.ConversationID, read, unread)
+	}
+
+	moved, err := session.WithTransaction(ctx, callback)
+	if err != nil {
+		return fmt.Errorf("transaction error: %w", err)
+	}
+	if moved.(bool) {
+		h.notifyUnreadCounts(ctx, event.ConversationID, []string{event.UserID})
+	}
+	return nil
+}
+
+// notifiedRecipients returns the recipients of a message whose notification settings let them be notified of it,
+// all of them if the settings can't be read.
+func (h *MessageProcessor) notifiedRecipients(ctx context.Context, message model.Message, recipients []string) []string {
+	log := logger.FromContext(ctx)
+
+	if len(recipients) == 0 {
+		return recipients
+	}
+	settings, err := h.settingsRepository.GetSettings(ctx, message.ConversationID, recipients)
+	if err != nil {
+		log.Error("Failed to get notification settings", zap.String("conversationID", message.ConversationID), zap.Error(err))
+		return recipients
+	}
+	now := time.Now()
+	for _, userSettings := range settings {
+		if !userSettings.Notifies(message, now) {
+			recipients = slices.DeleteFunc(recipients, func(user string) bool { return user == userSettings.UserID })
+		}
+	}
+	return recipients
+}
+
+// notifyUnreadCounts pushes the unread counts of a conversation to the devices of the users.
+// Notifications are best effort, the counts are also listed in the inbox.
+func (h *MessageProcessor) notifyUnreadCounts(ctx context.Context, conversationID string, users []string) {
+	log := logger.FromContext(ctx)
+
+	if len(users) == 0 {
+		return
+	}
+	counts, err := h.inboxRepository.GetUnreadCounts(ctx, conversationID, users)
+	if err != nil {
+		log.Error("Failed to get unread counts", zap.String("conversationID", conversationID), zap.Error(err))
+		return
+	}
+	if err := h.notifier.NotifyUnreadCounts(ctx, counts); err != nil {
+		log.Error("Failed to notify unread counts", zap.String("conversationID", conversationID), zap.Error(err))
+	}
+}
+
 // insertMessageWithOutbox inserts a message into the messages collection and creates an outbox event in a transaction.
-func (h *MessageProcessor) insertMessageWithOutbox(ctx context.Context, message model.Message) error {
+// The inboxes of the participants are updated in the same transaction, so redelivered messages are not counted twice,
+// nor counted at all by the blockers of the sender.
+// Replies in a thread also update the reply count of the root message, whose new version gets an outbox event too.
+func (h *MessageProcessor) insertMessageWithOutbox(ctx context.Context, message model.Message, participants []string, blockers []string) error {
 	// Start a session
 	session, err := h.client.StartSession()
 	if err != nil {
@@ -63,6 +539,23 @@ func (h *MessageProcessor) insertMessageWithOutbox(ctx context.Context, message
 			return nil, fmt.Errorf("insert outbox event error: %w", err)
 		}
 
+		// Showing the message in the inbox of the participants
+		if err := h.inboxRepository.AddMessage(sessionCtx, message, participants, blockers); err != nil {
+			return nil, err
+		}
+
+		// Count the reply in the root message of its thread
+		if message.ThreadRootID != "" {
+			root, err := h.messageRepository.AddReply(sessionCtx, message)
+			if err != nil {
+				return nil, fmt.Errorf("add reply error: %w", err)
+			}
+			err = h.outboxMessageRepository.WriteOutboxMessage(sessionCtx, root)
+			if err != nil {
+				return nil, fmt.Errorf("insert outbox event error: %w", err)
+			}
+		}
+
 		return nil, nil
 	}
 
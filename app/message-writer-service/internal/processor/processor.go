@@ -2,8 +2,12 @@ package processor
 
 import (
 	"context"
+	"errors"
 )
 
+// ErrRejected marks events that can never be processed, they are dropped instead of being requeued.
+var ErrRejected = errors.New("event rejected")
+
 // Processor defines the interface for handling messages events.
 type Processor[T any] interface {
 	// Implement the logic to process a message event.
@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/model"
+)
+
+type BlockRepository interface {
+	// WriteBlock blocks or unblocks a user, changes older than the stored one are ignored.
+	WriteBlock(ctx context.Context, block model.Block) error
+	// ListBlocked retrieves the users currently blocked by a user.
+	ListBlocked(ctx context.Context, userID string) ([]string, error)
+	// ListBlockers retrieves the users among the given ones who currently block a user.
+	ListBlockers(ctx context.Context, userID string, users []string) ([]string, error)
+	CreateIndexes(ctx context.Context) error
+}
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/model"
+)
+
+// ErrGroupNotFound is returned when no group matches the requested ID.
+var ErrGroupNotFound = errors.New("group not found")
+
+type GroupRepository interface {
+	WriteGroup(ctx context.Context, group model.Group) error
+	GetGroup(ctx context.Context, groupID string) (model.Group, error)
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/model"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// InboxRepository maintains the summary of each conversation of a user: the last message, the last activity time and the unread count.
+type InboxRepository interface {
+	// AddMessage shows a new message in the inbox of the participants of its conversation,
+	// counting it as unread for all but its sender and the blockers of its sender, unless they read a later message.
+	AddMessage(ctx mongo.SessionContext, message model.Message, participants []string, blockers []string) error
+	// MarkRead moves the read position of a user in a conversation forward and sets the messages left unread after it.
+	// It returns false if the position is not newer than the stored one, or the conversation is not in the inbox.
+	MarkRead(ctx mongo.SessionContext, userID string, conversationID string, read model.ReceiptPosition, unread int64) (bool, error)
+	// GetUnreadCounts retrieves the unread count of a conversation for each of the given users having it in their inbox.
+	GetUnreadCounts(ctx context.Context, conversationID string, users []string) ([]model.UnreadCount, error)
+	// UpdateMessage replaces the preview of a changed message shown as the last one of its conversation.
+	UpdateMessage(ctx mongo.SessionContext, message model.Message) error
+	// AddConversation shows a conversation without messages yet in the inbox of its participants.
+	AddConversation(ctx context.Context, conversationID string, participants []string, at time.Time) error
+	CreateIndexes(ctx context.Context) error
+}
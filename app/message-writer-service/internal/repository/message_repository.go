@@ -1,11 +1,31 @@
 package repository
 
 import (
+	"context"
+	"errors"
+
 	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/model"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+var (
+	// ErrMessageNotFound is returned when no message matches the requested ID.
+	ErrMessageNotFound = errors.New("message not found")
+	// ErrMessageNotModified is returned when a change doesn't apply to the message, because it is stale or not allowed.
+	ErrMessageNotModified = errors.New("message not modified")
+)
+
 type MessageRepository interface {
 	Client() *mongo.Client
 	WriteMessage(ctx mongo.SessionContext, message model.Message) error
+	GetMessage(ctx context.Context, messageID string) (model.Message, error)
+	EditMessage(ctx mongo.SessionContext, edit model.MessageEdit) (model.Message, error)
+	DeleteMessage(ctx mongo.SessionContext, del model.MessageDelete) (model.Message, error)
+	HideMessage(ctx mongo.SessionContext, del model.MessageDelete) (model.Message, error)
+	AddReaction(ctx mongo.SessionContext, event model.MessageEvent) (model.Message, error)
+	RemoveReaction(ctx mongo.SessionContext, event model.MessageEvent) (model.Message, error)
+	AddReply(ctx mongo.SessionContext, reply model.Message) (model.Message, error)
+	RemoveReply(ctx mongo.SessionContext, reply model.Message) (model.Message, error)
+	CountUnread(ctx context.Context, conversationID string, userID string, read *model.ReceiptPosition, blocked []string) (int64, error)
+	CreateIndexes(ctx context.Context) error
 }
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoBlockRepository struct {
+	client         *mongo.Client
+	dbName         string
+	collectionName string
+	collection     *mongo.Collection
+}
+
+func NewMongoBlockRepository(client *mongo.Client, dbName string, collectionName string) *MongoBlockRepository {
+	return &MongoBlockRepository{
+		client:         client,
+		dbName:         dbName,
+		collectionName: collectionName,
+		collection:     client.Database(dbName).Collection(collectionName),
+	}
+}
+
+func (r *MongoBlockRepository) WriteBlock(ctx context.Context, block model.Block) error {
+	// Blocks are stored per blocking and blocked user, unblocking keeps the document so later changes can be ordered
+	id := fmt.Sprintf("%s|%s", block.UserID, block.BlockedUserID)
+
+	// Only matching when the stored change is older, so a block and an unblock processed out of order can't be swapped
+	filter := bson.M{
+		"_id":       id,
+		"updatedAt": bson.M{"$lt": block.UpdatedAt},
+	}
+	update := bson.M{"$set": block}
+
+	_, err := r.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// The block exists with a newer change, the upsert tried to insert it again
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("upsert block error: %w", err)
+	}
+	return nil
+}
+
+func (r *MongoBlockRepository) ListBlocked(ctx context.Context, userID string) ([]string, error) {
+	blocks, err := r.findBlocks(ctx, bson.M{"userId": userID, "blocked": true})
+	if err != nil {
+		return nil, err
+	}
+	blocked := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		blocked = append(blocked, block.BlockedUserID)
+	}
+	return blocked, nil
+}
+
+func (r *MongoBlockRepository) ListBlockers(ctx context.Context, userID string, users []string) ([]string, error) {
+	if len(users) == 0 {
+		return nil, nil
+	}
+	blocks, err := r.findBlocks(ctx, bson.M{"blockedUserId": userID, "blocked": true, "userId": bson.M{"$in": users}})
+	if err != nil {
+		return nil, err
+	}
+	blockers := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		blockers = append(blockers, block.UserID)
+	}
+	return blockers, nil
+}
+
+func (r *MongoBlockRepository) findBlocks(ctx context.Context, filter any) ([]model.Block, error) {
+	opts := options.Find().SetProjection(bson.M{"userId": 1, "blockedUserId": 1})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("find blocks error: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var blocks []model.Block
+	if err := cursor.All(ctx, &blocks); err != nil {
+		return nil, fmt.Errorf("decode blocks error: %w", err)
+	}
+	return blocks, nil
+}
+
+// CreateIndexes creates the indexes used to list the users a user blocked, and the users who blocked a user.
+func (r *MongoBlockRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "blocked", Value: 1}, {Key: "updatedAt", Value: -1}},
+			Options: options.Index().SetName("user_blocks"),
+		},
+		{
+			Keys:    bson.D{{Key: "blockedUserId", Value: 1}, {Key: "blocked", Value: 1}, {Key: "userId", Value: 1}},
+			Options: options.Index().SetName("user_blockers"),
+		},
+	}
+	if _, err := r.collection.Indexes().CreateMany(ctx, indexes); err != nil {
+		return fmt.Errorf("create indexes error: %w", err)
+	}
+	return nil
+}
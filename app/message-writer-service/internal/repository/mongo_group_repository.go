@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoGroupRepository struct {
+	client         *mongo.Client
+	dbName         string
+	collectionName string
+	collection     *mongo.Collection
+}
+
+func NewMongoGroupRepository(client *mongo.Client, dbName string, collectionName string) *MongoGroupRepository {
+	return &MongoGroupRepository{
+		client:         client,
+		dbName:         dbName,
+		collectionName: collectionName,
+		collection:     client.Database(dbName).Collection(collectionName),
+	}
+}
+
+// WriteGroup stores a group, replacing it if it already exists so redelivered events stay idempotent.
+func (r *MongoGroupRepository) WriteGroup(ctx context.Context, group model.Group) error {
+	opts := options.Replace().SetUpsert(true)
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": group.ID}, group, opts)
+	if err != nil {
+		return fmt.Errorf("upsert group error: %w", err)
+	}
+	return nil
+}
+
+// GetGroup returns the group with the given ID, or ErrGroupNotFound if it was never stored.
+func (r *MongoGroupRepository) GetGroup(ctx context.Context, groupID string) (model.Group, error) {
+	var group model.Group
+	err := r.collection.FindOne(ctx, bson.M{"_id": groupID}).Decode(&group)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return model.Group{}, ErrGroupNotFound
+	}
+	if err != nil {
+		return model.Group{}, fmt.Errorf("find group error: %w", err)
+	}
+	return group, nil
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// previewLength is the number of characters of a message kept in inbox previews.
+const previewLength = 100
+
+type MongoInboxRepository struct {
+	client         *mongo.Client
+	dbName         string
+	collectionName string
+	collection     *mongo.Collection
+}
+
+func NewMongoInboxRepository(client *mongo.Client, dbName string, collectionName string) *MongoInboxRepository {
+	return &MongoInboxRepository{
+		client:         client,
+		dbName:         dbName,
+		collectionName: collectionName,
+		collection:     client.Database(dbName).Collection(collectionName),
+	}
+}
+
+func (r *MongoInboxRepository) AddMessage(ctx mongo.SessionContext, message model.Message, participants []string, blockers []string) error {
+	preview := previewMessage(message)
+	models := make([]mongo.WriteModel, 0, len(participants))
+	for _, user := range participants {
+		// Messages written after the user read a later one are not unread
+		var unread any = bson.M{"$cond": bson.A{
+			bson.M{"$gt": bson.A{message.Timestamp, bson.M{"$ifNull": bson.A{"$lastRead.messageTimestamp", time.Time{}}}}},
+			1,
+			0,
+		}}
+		if user == message.Sender || slices.Contains(blockers, user) {
+			unread = 0
+		}
+		// Expressions of a single stage see the summary before the update.
+		// Messages may be written out of order, only newer ones replace the last message.
+		update := bson.A{
+			bson.M{"$set": bson.M{
+				"userId":         user,
+				"conversationId": message.ConversationID,
+				"lastMessage": bson.M{"$cond": bson.A{
+					bson.M{"$gt": bson.A{message.Timestamp, bson.M{"$ifNull": bson.A{"$lastMessage.timestamp", time.Time{}}}}},
+					// Content is stored as is, not as an expression
+					bson.M{"$literal": preview},
+					"$lastMessage",
+				}},
+				"lastActivityAt": bson.M{"$max": bson.A{"$lastActivityAt", message.Timestamp}},
+				"unreadCount":    bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$unreadCount", 0}}, unread}},
+			}},
+		}
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": inboxID(user, message.ConversationID)}).
+			SetUpdate(update).
+			SetUpsert(true))
+	}
+	if len(models) == 0 {
+		return nil
+	}
+
+	if _, err := r.collection.BulkWrite(ctx, models); err != nil {
+		return fmt.Errorf("update inbox error: %w", err)
+	}
+	return nil
+}
+
+func (r *MongoInboxRepository) UpdateMessage(ctx mongo.SessionContext, message model.Message) error {
+	// Older versions never replace newer ones
+	filter := bson.M{
+		"conversationId":      message.ConversationID,
+		"lastMessage._id":     message.ID,
+		"lastMessage.version": bson.M{"$lt": message.Version},
+	}
+	update := bson.M{"$set": bson.M{"lastMessage": previewMessage(message)}}
+
+	if _, err := r.collection.UpdateMany(ctx, filter, update); err != nil {
+		return fmt.Errorf("update inbox error: %w", err)
+	}
+	return nil
+}
+
+func (r *MongoInboxRepository) MarkRead(ctx mongo.SessionContext, userID string, conversationID string, read model.ReceiptPosition, unread int64) (bool, error) {
+	// Only matching when the stored position is older, so out of order receipts can't move it backwards
+	filter := bson.M{
+		"_id": inboxID(userID, conversationID),
+		"$or": bson.A{
+			bson.M{"lastRead": bson.M{"$exists": false}},
+			bson.M{"lastRead.messageTimestamp": bson.M{"$lt": read.MessageTimestamp}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"lastRead":    read,
+			"unreadCount": unread,
+		},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, fmt.Errorf("update inbox error: %w", err)
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func (r *MongoInboxRepository) GetUnreadCounts(ctx context.Context, conversationID string, users []string) ([]model.UnreadCount, error) {
+	ids := make([]string, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, inboxID(user, conversationID))
+	}
+
+	cursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, fmt.Errorf("find inbox error: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var counts []model.UnreadCount
+	if err := cursor.All(ctx, &counts); err != nil {
+		return nil, fmt.Errorf("decode inbox error: %w", err)
+	}
+	return counts, nil
+}
+
+func (r *MongoInboxRepository) AddConversation(ctx context.Context, conversationID string, participants []string, at time.Time) error {
+	models := make([]mongo.WriteModel, 0, len(participants))
+	for _, user := range participants {
+		update := bson.M{
+			"$setOnInsert": bson.M{
+				"userId":         user,
+				"conversationId": conversationID,
+				"unreadCount":    0,
+			},
+			"$max": bson.M{"lastActivityAt": at},
+		}
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": inboxID(user, conversationID)}).
+			SetUpdate(update).
+			SetUpsert(true))
+	}
+	if len(models) == 0 {
+		return nil
+	}
+
+	if _, err := r.collection.BulkWrite(ctx, models); err != nil {
+		return fmt.Errorf("update inbox error: %w", err)
+	}
+	return nil
+}
+
+// CreateIndexes creates the index used to list the conversations of a user, most recently active first.
+func (r *MongoInboxRepository) CreateIndexes(ctx context.Context) error {
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "lastActivityAt", Value: -1}, {Key: "conversationId", Value: -1}},
+		Options: options.Index().SetName("user_inbox"),
+	}
+	if _, err := r.collection.Indexes().CreateOne(ctx, index); err != nil {
+		return fmt.Errorf("create indexes error: %w", err)
+	}
+	return nil
+}
+
+// inboxID returns the ID of the summary of a conversation in the inbox of a user.
+func inboxID(userID string, conversationID string) string {
+	return fmt.Sprintf("%s|%s", userID, conversationID)
+}
+
+// previewMessage returns the message shown in inboxes, its content truncated and without its reactions and the users who hid it.
+func previewMessage(message model.Message) model.Message {
+	preview := message
+	if utf8.RuneCountInString(preview.Content) > previewLength {
+		preview.Content = string([]rune(preview.Content)[:previewLength])
+	}
+	preview.HiddenFor = nil
+	preview.Reactions = nil
+	return preview
+}
@@ -1,10 +1,14 @@
 package repository
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
 	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type MongoMessageRepository struct {
@@ -34,3 +38,214 @@ func (r *MongoMessageRepository) WriteMessage(ctx mongo.SessionContext, message
 	}
 	return nil
 }
+
+// GetMessage returns the message with the given ID, or ErrMessageNotFound if it was never written.
+func (r *MongoMessageRepository) GetMessage(ctx context.Context, messageID string) (model.Message, error) {
+	var message model.Message
+	err := r.collection.FindOne(ctx, bson.M{"_id": messageID}).Decode(&message)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return model.Message{}, ErrMessageNotFound
+	}
+	if err != nil {
+		return model.Message{}, fmt.Errorf("find message error: %w", err)
+	}
+	return message, nil
+}
+
+// EditMessage replaces the content of a message sent by the editor, keeping the prior content in its revisions.
+// Edits older than the last one are not applied, so redelivered edits stay idempotent.
+func (r *MongoMessageRepository) EditMessage(ctx mongo.SessionContext, edit model.MessageEdit) (model.Message, error) {
+	filter := bson.M{
+		"_id":            edit.MessageID,
+		"conversationId": edit.ConversationID,
+		"sender":         edit.Sender,
+		"deletedAt":      bson.M{"$exists": false},
+		"$or": bson.A{
+			bson.M{"editedAt": bson.M{"$exists": false}},
+			bson.M{"editedAt": bson.M{"$lt": edit.EditedAt}},
+		},
+	}
+	// Expressions of a single stage see the message before the update
+	update := bson.A{
+		bson.M{"$set": bson.M{
+			"revisions": bson.M{"$concatArrays": bson.A{
+				bson.M{"$ifNull": bson.A{"$revisions", bson.A{}}},
+				bson.A{bson.M{
+					"content":  "$content",
+					"editedAt": bson.M{"$ifNull": bson.A{"$editedAt", "$timestamp"}},
+				}},
+			}},
+			"content":  edit.Content,
+			"editedAt": edit.EditedAt,
+			"version":  bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$version", 0}}, 1}},
+		}},
+	}
+
+	return r.updateMessage(ctx, filter, update)
+}
+
+// DeleteMessage tombstones a message for everyone, clearing its content, revisions, attachments and mentions.
+// Authorization is left to the caller, deleting a message twice doesn't modify it.
+func (r *MongoMessageRepository) DeleteMessage(ctx mongo.SessionContext, del model.MessageDelete) (model.Message, error) {
+	filter := bson.M{
+		"_id":            del.MessageID,
+		"conversationId": del.ConversationID,
+		"deletedAt":      bson.M{"$exists": false},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"content":   "",
+			"deletedAt": del.DeletedAt,
+			"deletedBy": del.UserID,
+		},
+		"$unset": bson.M{"revisions": "", "reactions": "", "attachments": "", "mentions": ""},
+		"$inc":   bson.M{"version": 1},
+	}
+	return r.updateMessage(ctx, filter, update)
+}
+
+// HideMessage hides a message from the history of the deleting user only, hiding it twice doesn't modify it.
+func (r *MongoMessageRepository) HideMessage(ctx mongo.SessionContext, del model.MessageDelete) (model.Message, error) {
+	filter := bson.M{
+		"_id":            del.MessageID,
+		"conversationId": del.ConversationID,
+		"hiddenFor":      bson.M{"$ne": del.UserID},
+	}
+	update := bson.M{
+		"$push": bson.M{"hiddenFor": del.UserID},
+		"$inc":  bson.M{"version": 1},
+	}
+	return r.updateMessage(ctx, filter, update)
+}
+
+// AddReaction adds the reaction of a user to a message not deleted for everyone, adding it twice doesn't modify it.
+func (r *MongoMessageRepository) AddReaction(ctx mongo.SessionContext, event model.MessageEvent) (model.Message, error) {
+	field := "reactions." + event.Reaction
+	filter := bson.M{
+		"_id":            event.MessageID,
+		"conversationId": event.ConversationID,
+		"deletedAt":      bson.M{"$exists": false},
+		field:            bson.M{"$ne": event.UserID},
+	}
+	update := bson.M{
+		"$push": bson.M{field: event.UserID},
+		"$inc":  bson.M{"version": 1},
+	}
+	return r.updateMessage(ctx, filter, update)
+}
+
+// RemoveReaction removes the reaction of a user from a message, removing it twice doesn't modify it.
+func (r *MongoMessageRepository) RemoveReaction(ctx mongo.SessionContext, event model.MessageEvent) (model.Message, error) {
+	field := "reactions." + event.Reaction
+	filter := bson.M{
+		"_id":            event.MessageID,
+		"conversationId": event.ConversationID,
+		field:            event.UserID,
+	}
+	update := bson.M{
+		"$pull": bson.M{field: event.UserID},
+		"$inc":  bson.M{"version": 1},
+	}
+	return r.updateMessage(ctx, filter, update)
+}
+
+// AddReply counts a reply in the thread of its root message and keeps the time of the latest reply.
+func (r *MongoMessageRepository) AddReply(ctx mongo.SessionContext, reply model.Message) (model.Message, error) {
+	filter := bson.M{
+		"_id":            reply.ThreadRootID,
+		"conversationId": reply.ConversationID,
+	}
+	update := bson.M{
+		"$inc": bson.M{"replyCount": 1, "version": 1},
+		"$max": bson.M{"lastReplyAt": reply.Timestamp},
+	}
+	return r.updateMessage(ctx, filter, update)
+}
+
+// RemoveReply uncounts a reply deleted for everyone from the thread of its root message,
+// and keeps the time of the latest reply left, the reply must already be deleted.
+func (r *MongoMessageRepository) RemoveReply(ctx mongo.SessionContext, reply model.Message) (model.Message, error) {
+	var latest model.Message
+	opts := options.FindOne().SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}})
+	err := r.collection.FindOne(ctx, bson.M{
+		"threadRootId": reply.ThreadRootID,
+		"deletedAt":    bson.M{"$exists": false},
+	}, opts).Decode(&latest)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return model.Message{}, fmt.Errorf("find latest reply error: %w", err)
+	}
+
+	filter := bson.M{
+		"_id":            reply.ThreadRootID,
+		"conversationId": reply.ConversationID,
+		"replyCount":     bson.M{"$gt": 0},
+	}
+	update := bson.M{
+		"$inc": bson.M{"replyCount": -1, "version": 1},
+	}
+	if latest.ID != "" {
+		update["$set"] = bson.M{"lastReplyAt": latest.Timestamp}
+	} else {
+		update["$unset"] = bson.M{"lastReplyAt": ""}
+	}
+	return r.updateMessage(ctx, filter, update)
+}
+
+// CountUnread counts the messages of a conversation the user didn't send after the read position, or all of them without position.
+// Messages deleted for everyone, hidden by the user or sent by the blocked users are not counted.
+func (r *MongoMessageRepository) CountUnread(ctx context.Context, conversationID string, userID string, read *model.ReceiptPosition, blocked []string) (int64, error) {
+	filter := bson.M{
+		"conversationId": conversationID,
+		"sender":         bson.M{"$nin": append([]string{userID}, blocked...)},
+		"deletedAt":      bson.M{"$exists": false},
+		"hiddenFor":      bson.M{"$ne": userID},
+	}
+	// Same order as the conversation history
+	if read != nil {
+		filter["$or"] = bson.A{
+			bson.M{"timestamp": bson.M{"$gt": read.MessageTimestamp}},
+			bson.M{"timestamp": read.MessageTimestamp, "_id": bson.M{"$gt": read.MessageID}},
+		}
+	}
+
+	count, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("count unread messages error: %w", err)
+	}
+	return count, nil
+}
+
+// CreateIndexes creates the indexes used to page through conversations and threads, newest first.
+// Creating existing indexes again doesn't change them.
+func (r *MongoMessageRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "conversationId", Value: 1}, {Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}},
+			Options: options.Index().SetName("conversation_history"),
+		},
+		{
+			Keys: bson.D{{Key: "threadRootId", Value: 1}, {Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}},
+			// Most messages are not replies in threads
+			Options: options.Index().SetName("thread_replies").SetPartialFilterExpression(bson.M{"threadRootId": bson.M{"$exists": true}}),
+		},
+	}
+	if _, err := r.collection.Indexes().CreateMany(ctx, indexes); err != nil {
+		return fmt.Errorf("create indexes error: %w", err)
+	}
+	return nil
+}
+
+// updateMessage applies an update to the message matching the filter and returns its new version,
+// or ErrMessageNotModified if none matches.
+func (r *MongoMessageRepository) updateMessage(ctx mongo.SessionContext, filter any, update any) (model.Message, error) {
+	var message model.Message
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&message)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return model.Message{}, ErrMessageNotModified
+	}
+	if err != nil {
+		return model.Message{}, fmt.Errorf("update message error: %w", err)
+	}
+	return message, nil
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoNotificationSettingsRepository struct {
+	client         *mongo.Client
+	dbName         string
+	collectionName string
+	collection     *mongo.Collection
+}
+
+func NewMongoNotificationSettingsRepository(client *mongo.Client, dbName string, collectionName string) *MongoNotificationSettingsRepository {
+	return &MongoNotificationSettingsRepository{
+		client:         client,
+		dbName:         dbName,
+		collectionName: collectionName,
+		collection:     client.Database(dbName).Collection(collectionName),
+	}
+}
+
+func (r *MongoNotificationSettingsRepository) WriteSettings(ctx context.Context, settings model.NotificationSettings) error {
+	// Settings are stored per user and conversation, as inbox summaries
+	filter := bson.M{
+		"_id":       inboxID(settings.UserID, settings.ConversationID),
+		"updatedAt": bson.M{"$lt": settings.UpdatedAt},
+	}
+	// Replacing the whole settings, so unmuting clears the mute time
+	set := bson.M{
+		"userId":         settings.UserID,
+		"conversationId": settings.ConversationID,
+		"level":          settings.Level,
+		"updatedAt":      settings.UpdatedAt,
+	}
+	update := bson.M{"$set": set}
+	if settings.MutedUntil.IsZero() {
+		update["$unset"] = bson.M{"mutedUntil": ""}
+	} else {
+		set["mutedUntil"] = settings.MutedUntil
+	}
+
+	_, err := r.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// The settings exist with a newer change, the upsert tried to insert them again
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("upsert notification settings error: %w", err)
+	}
+	return nil
+}
+
+func (r *MongoNotificationSettingsRepository) GetSettings(ctx context.Context, conversationID string, users []string) ([]model.NotificationSettings, error) {
+	ids := make([]string, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, inboxID(user, conversationID))
+	}
+	cursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, fmt.Errorf("find notification settings error: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var settings []model.NotificationSettings
+	if err := cursor.All(ctx, &settings); err != nil {
+		return nil, fmt.Errorf("decode notification settings error: %w", err)
+	}
+	return settings, nil
+}
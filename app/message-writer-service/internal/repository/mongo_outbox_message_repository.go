@@ -33,8 +33,13 @@ func (r *MongoOutboxMessageRepository) Client() *mongo.Client {
 }
 
 func (r *MongoOutboxMessageRepository) WriteOutboxMessage(ctx mongo.SessionContext, message model.Message) error {
+	// Each version of a message is dispatched once
+	id := message.ID
+	if message.Version > 0 {
+		id = fmt.Sprintf("%s@%d", message.ID, message.Version)
+	}
 	outboxMessage := model.OutboxMessage{
-		ID:          message.ID,
+		ID:          id,
 		Payload:     message,
 		CreatedAt:   time.Now().UTC(),
 		ProcessedAt: time.Time{},
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoReceiptRepository struct {
+	client         *mongo.Client
+	dbName         string
+	collectionName string
+	collection     *mongo.Collection
+}
+
+func NewMongoReceiptRepository(client *mongo.Client, dbName string, collectionName string) *MongoReceiptRepository {
+	return &MongoReceiptRepository{
+		client:         client,
+		dbName:         dbName,
+		collectionName: collectionName,
+		collection:     client.Database(dbName).Collection(collectionName),
+	}
+}
+
+func (r *MongoReceiptRepository) WriteReceipt(ctx context.Context, event model.MessageEvent) error {
+	// Receipts are stored per participant and conversation, "delivered" or "read" is the updated field
+	id := fmt.Sprintf("%s|%s", event.ConversationID, event.UserID)
+	field := event.Type
+
+	// Only matching when the stored position is older, so out of order events can't move it backwards
+	filter := bson.M{
+		"_id": id,
+		"$or": bson.A{
+			bson.M{field: bson.M{"$exists": false}},
+			bson.M{field + ".messageTimestamp": bson.M{"$lt": event.MessageTimestamp}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"conversationId": event.ConversationID,
+			"userId":         event.UserID,
+			field: model.ReceiptPosition{
+				MessageID:        event.MessageID,
+				MessageTimestamp: event.MessageTimestamp,
+				ReportedAt:       event.Timestamp,
+			},
+		},
+	}
+
+	_, err := r.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// The receipt exists with a newer position, the upsert tried to insert it again
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("upsert receipt error: %w", err)
+	}
+	return nil
+}
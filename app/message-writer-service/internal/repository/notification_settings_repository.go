@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/model"
+)
+
+type NotificationSettingsRepository interface {
+	// WriteSettings stores the notification settings of a user for a conversation, changes older than the stored one are ignored.
+	WriteSettings(ctx context.Context, settings model.NotificationSettings) error
+	// GetSettings retrieves the notification settings of a conversation for each of the given users who changed them.
+	GetSettings(ctx context.Context, conversationID string, users []string) ([]model.NotificationSettings, error)
+}
@@ -0,0 +1,12 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/model"
+)
+
+type ReceiptRepository interface {
+	// WriteReceipt advances the delivered or read position of a participant, older positions are ignored.
+	WriteReceipt(ctx context.Context, event model.MessageEvent) error
+}
@@ -0,0 +1,12 @@
+package service
+
+import (
+	"context"
+
+	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/model"
+)
+
+// Notifier pushes changes of the state of users to their connected devices.
+type Notifier interface {
+	NotifyUnreadCounts(ctx context.Context, counts []model.UnreadCount) error
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisNotifier publishes events to the Redis channels of users, which the chat-service forwards to all of their sockets.
+type RedisNotifier struct {
+	client *redis.Client
+}
+
+func NewRedisNotifier(client *redis.Client) *RedisNotifier {
+	return &RedisNotifier{
+		client: client,
+	}
+}
+
+// NotifyUnreadCounts publishes an UnreadCount event to each user in a single round trip.
+func (n *RedisNotifier) NotifyUnreadCounts(ctx context.Context, counts []model.UnreadCount) error {
+	pipe := n.client.Pipeline()
+	for _, count := range counts {
+		content, err := json.Marshal(count)
+		if err != nil {
+			return err
+		}
+		event, err := json.Marshal(model.Event{
+			Type:      model.EventTypeUnreadCount,
+			EventID:   rand.Text(),
+			Timestamp: time.Now().UTC(),
+			Content:   content,
+		})
+		if err != nil {
+			return err
+		}
+		pipe.Publish(ctx, fmt.Sprintf("user:%s", count.UserID), event)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
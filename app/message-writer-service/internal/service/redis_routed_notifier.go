@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisRoutedNotifier publishes events to the Redis channels of the chat-service replicas holding the sockets of users,
+// as found in the route registry of the chat-service.
+type RedisRoutedNotifier struct {
+	client *redis.Client
+}
+
+func NewRedisRoutedNotifier(client *redis.Client) *RedisRoutedNotifier {
+	return &RedisRoutedNotifier{
+		client: client,
+	}
+}
+
+// NotifyUnreadCounts looks up the replicas of all users, then publishes an UnreadCount event to each of them, in two round trips.
+// Users without sockets are not notified.
+func (n *RedisRoutedNotifier) NotifyUnreadCounts(ctx context.Context, counts []model.UnreadCount) error {
+	// Replicas are scored by the expiry of their heartbeat, those that stopped heartbeating are left out
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	lookup := n.client.Pipeline()
+	replicas := make([]*redis.StringSliceCmd, len(counts))
+	for i, count := range counts {
+		replicas[i] = lookup.ZRangeByScore(ctx, fmt.Sprintf("route:%s", count.UserID), &redis.ZRangeBy{Min: now, Max: "+inf"})
+	}
+	if _, err := lookup.Exec(ctx); err != nil {
+		return err
+	}
+
+	pipe := n.client.Pipeline()
+	published := false
+	for i, count := range counts {
+		if len(replicas[i].Val()) == 0 {
+			continue
+		}
+		content, err := json.Marshal(count)
+		if err != nil {
+			return err
+		}
+		event, err := json.Marshal(model.Event{
+			Type:      model.EventTypeUnreadCount,
+			EventID:   rand.Text(),
+			Timestamp: time.Now().UTC(),
+			Content:   content,
+		})
+		if err != nil {
+			return err
+		}
+		routed, err := json.Marshal(model.RoutedEvent{
+			Users: []string{count.UserID},
+			Event: event,
+		})
+		if err != nil {
+			return err
+		}
+		for _, replica := range replicas[i].Val() {
+			pipe.Publish(ctx, fmt.Sprintf("replica:%s", replica), routed)
+			published = true
+		}
+	}
+	if !published {
+		return nil
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStreamNotifier appends events to the Redis streams of users, which the chat-service delivers to all of their sockets,
+// including those reconnecting shortly after.
+type RedisStreamNotifier struct {
+	client *redis.Client
+	maxLen int64
+	ttl    time.Duration
+}
+
+func NewRedisStreamNotifier(client *redis.Client, maxLen int64, ttl time.Duration) *RedisStreamNotifier {
+	return &RedisStreamNotifier{
+		client: client,
+		maxLen: maxLen,
+		ttl:    ttl,
+	}
+}
+
+// NotifyUnreadCounts appends an UnreadCount event to the stream of each user in a single round trip.
+func (n *RedisStreamNotifier) NotifyUnreadCounts(ctx context.Context, counts []model.UnreadCount) error {
+	pipe := n.client.Pipeline()
+	for _, count := range counts {
+		content, err := json.Marshal(count)
+		if err != nil {
+			return err
+		}
+		event, err := json.Marshal(model.Event{
+			Type:      model.EventTypeUnreadCount,
+			EventID:   rand.Text(),
+			Timestamp: time.Now().UTC(),
+			Content:   content,
+		})
+		if err != nil {
+			return err
+		}
+		stream := fmt.Sprintf("stream:user:%s", count.UserID)
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: stream,
+			MaxLen: n.maxLen,
+			Approx: true,
+			Values: map[string]any{"event": string(event)},
+		})
+		pipe.Expire(ctx, stream, n.ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
@@ -2,6 +2,7 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"sync"
 
 	"github.com/nsmsb/darda-chat/app/message-writer-service/internal/processor"
@@ -71,6 +72,12 @@ func (wp *WorkerPool[T]) Start(ctx context.Context) error {
 
 				// Process the event & log any errors.
 				err := wp.processor.Process(ctx, eventEnvelope.Payload)
+				if errors.Is(err, processor.ErrRejected) {
+					// Retrying would fail again, the event is dropped
+					log.Warn("Rejected event", zap.String("event_id", eventEnvelope.ID), zap.Error(err))
+					wp.source.Nack(eventEnvelope.DeliveryTag, false)
+					return
+				}
 				if err != nil {
 					log.Error("Error processing event", zap.String("event_id", eventEnvelope.ID), zap.Error(err))
 					wp.source.Nack(eventEnvelope.DeliveryTag, true)
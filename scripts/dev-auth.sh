@@ -0,0 +1,57 @@
+#!/usr/bin/env bash
+
+# Development access tokens of the chat-service, signed with a local RSA key pair.
+#
+#   scripts/dev-auth.sh secret        creates the key pair if missing, and the chat-service-auth Secret holding its public key
+#   scripts/dev-auth.sh token <user>  prints an access token of the user, valid for TOKEN_TTL seconds (default: one day)
+#
+# The private key never leaves KEY_DIR, the chat-service only gets the public key.
+
+set -e
+
+PROJECT_ROOT="$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)"
+KEY_DIR="${KEY_DIR:-$PROJECT_ROOT/.dev-auth}"
+TOKEN_TTL="${TOKEN_TTL:-86400}"
+
+# Must match AUTH_ISSUER and AUTH_AUDIENCE of manifests/chat-service/configmap.yaml
+ISSUER="darda-chat-dev"
+AUDIENCE="chat-service"
+
+generate_keys() {
+  if [ -f "$KEY_DIR/private-key.pem" ]; then
+    return
+  fi
+  mkdir -p "$KEY_DIR"
+  openssl genpkey -algorithm RSA -pkeyopt rsa_keygen_bits:2048 -out "$KEY_DIR/private-key.pem" 2>/dev/null
+  openssl pkey -in "$KEY_DIR/private-key.pem" -pubout -out "$KEY_DIR/public-key.pem"
+  echo "Generated key pair in $KEY_DIR" >&2
+}
+
+base64url() {
+  openssl base64 -A | tr '+/' '-_' | tr -d '='
+}
+
+case "$1" in
+  secret)
+    generate_keys
+    kubectl create secret generic chat-service-auth \
+      --from-file=public-key.pem="$KEY_DIR/public-key.pem" \
+      --dry-run=client -o yaml | kubectl apply -f -
+    ;;
+  token)
+    if [ -z "$2" ]; then
+      echo "Usage: $0 token <user>" >&2
+      exit 1
+    fi
+    generate_keys
+    exp=$(( $(date +%s) + TOKEN_TTL ))
+    header=$(printf '{"alg":"RS256","typ":"JWT"}' | base64url)
+    payload=$(printf '{"sub":"%s","iss":"%s","aud":"%s","exp":%d}' "$2" "$ISSUER" "$AUDIENCE" "$exp" | base64url)
+    signature=$(printf '%s.%s' "$header" "$payload" | openssl dgst -sha256 -sign "$KEY_DIR/private-key.pem" | base64url)
+    echo "$header.$payload.$signature"
+    ;;
+  *)
+    echo "Usage: $0 secret | token <user>" >&2
+    exit 1
+    ;;
+esac